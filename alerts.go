@@ -0,0 +1,370 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+const (
+	PaNyNjAlertsUrl = "https://www.panynj.gov/bin/portauthority/everbridge/incidents?status=All&department=Path"
+	// How often the feed retrieves alerts from the alert source.
+	alertsRefreshPeriod = time.Minute
+)
+
+// ServiceAlert is a service status message about the PATH system.
+type ServiceAlert struct {
+	Id          string
+	Header      string
+	Description string
+	Url         string
+	// The stations and routes affected by the alert. If both are empty the alert affects the whole system.
+	Stations []sourceapi.Station
+	Routes   []sourceapi.Route
+	// The times the alert is active between. Either may be the zero time if it is not known.
+	Start time.Time
+	End   time.Time
+}
+
+// AlertSource describes the methods that the feed generator requires from a source of service alerts.
+type AlertSource interface {
+	// List all current service alerts
+	GetAlerts(context.Context) ([]ServiceAlert, error)
+}
+
+// PaNyNjAlertSource is an alert source that gets PATH service status messages from the Port Authority of New York and New Jersey.
+//
+// Both the JSON incidents format used by the PATH website and RSS feeds are supported; the format is detected
+// from the content of the response.
+type PaNyNjAlertSource struct {
+	httpClient HttpClient
+	url        string
+}
+
+func NewPaNyNjAlertSource(httpClient HttpClient, url string) *PaNyNjAlertSource {
+	return &PaNyNjAlertSource{httpClient: httpClient, url: url}
+}
+
+func (source *PaNyNjAlertSource) GetAlerts(ctx context.Context) ([]ServiceAlert, error) {
+	content, err := source.getContent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("<")) {
+		return parseRssAlerts(content)
+	}
+	return parseJsonAlerts(content)
+}
+
+// Get the raw bytes from the alerts URL.
+func (source *PaNyNjAlertSource) getContent(ctx context.Context) (bytes []byte, err error) {
+	resp, err := getWithContext(ctx, source.httpClient, source.url)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closingErr := resp.Body.Close()
+		if err == nil {
+			err = closingErr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alerts URL returned status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseJsonAlerts(content []byte) ([]ServiceAlert, error) {
+	type jsonIncidentMessage struct {
+		Subject    string `json:"subject"`
+		PreMessage string `json:"preMessage"`
+	}
+	type jsonIncident struct {
+		Id              string              `json:"id"`
+		IncidentMessage jsonIncidentMessage `json:"incidentMessage"`
+		CreatedDate     string              `json:"createdDate"`
+		ExpiryDate      string              `json:"expiryDate"`
+		Url             string              `json:"url"`
+	}
+	type jsonIncidentsResponse struct {
+		Status string         `json:"status"`
+		Data   []jsonIncident `json:"data"`
+	}
+	response := jsonIncidentsResponse{}
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, err
+	}
+	var alerts []ServiceAlert
+	for _, incident := range response.Data {
+		alerts = append(alerts, newServiceAlert(
+			incident.Id,
+			incident.IncidentMessage.Subject,
+			incident.IncidentMessage.PreMessage,
+			incident.Url,
+			parseAlertTime(incident.CreatedDate),
+			parseAlertTime(incident.ExpiryDate),
+		))
+	}
+	return alerts, nil
+}
+
+func parseRssAlerts(content []byte) ([]ServiceAlert, error) {
+	type rssItem struct {
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Link        string `xml:"link"`
+		Guid        string `xml:"guid"`
+		PubDate     string `xml:"pubDate"`
+	}
+	type rssFeed struct {
+		Items []rssItem `xml:"channel>item"`
+	}
+	feed := rssFeed{}
+	if err := xml.Unmarshal(content, &feed); err != nil {
+		return nil, err
+	}
+	var alerts []ServiceAlert
+	for _, item := range feed.Items {
+		id := item.Guid
+		if id == "" {
+			id = item.Link
+		}
+		if id == "" {
+			id = rssItemId(item.Title, item.PubDate)
+		}
+		alerts = append(alerts, newServiceAlert(
+			id,
+			item.Title,
+			item.Description,
+			item.Link,
+			parseAlertTime(item.PubDate),
+			time.Time{},
+		))
+	}
+	return alerts, nil
+}
+
+// Returns an ID for an RSS item with no guid or link, derived from its title and publication date so that it is
+// the same each time the item is retrieved.
+func rssItemId(title, pubDate string) string {
+	h := fnv.New64a()
+	h.Write([]byte(strings.TrimSpace(title)))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.TrimSpace(pubDate)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func newServiceAlert(id, header, description, url string, start, end time.Time) ServiceAlert {
+	stations, routes := findAffectedStationsAndRoutes(header + "\n" + description)
+	return ServiceAlert{
+		Id:          id,
+		Header:      strings.TrimSpace(header),
+		Description: strings.TrimSpace(description),
+		Url:         url,
+		Stations:    stations,
+		Routes:      routes,
+		Start:       start,
+		End:         end,
+	}
+}
+
+func parseAlertTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, time.RFC1123Z, time.RFC1123} {
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// The ways routes are referred to in PATH service status messages. These are matched before
+// station names, and removed from the text, so that e.g. "Newark-World Trade Center" is
+// not interpreted as referring to the Newark and World Trade Center stations.
+var alertRoutePatterns = []struct {
+	route   sourceapi.Route
+	pattern *regexp.Regexp
+}{
+	{sourceapi.Route_JSQ_33_HOB, alertPhrasePattern("jsq-33 via hob", "journal square-33rd street via hoboken", "journal square-33rd st via hoboken")},
+	{sourceapi.Route_NWK_WTC, alertPhrasePattern("nwk-wtc", "newark-world trade center", "newark-wtc")},
+	{sourceapi.Route_NPT_HOB, alertPhrasePattern("npt-hob", "newport-hoboken")},
+	{sourceapi.Route_HOB_WTC, alertPhrasePattern("hob-wtc", "hoboken-world trade center", "hoboken-wtc")},
+	{sourceapi.Route_JSQ_33, alertPhrasePattern("jsq-33", "journal square-33rd street", "journal square-33rd st")},
+	{sourceapi.Route_HOB_33, alertPhrasePattern("hob-33", "hoboken-33rd street", "hoboken-33rd st")},
+}
+
+var alertStationPatterns = []struct {
+	station sourceapi.Station
+	pattern *regexp.Regexp
+}{
+	{sourceapi.Station_NEWARK, alertPhrasePattern("newark", "nwk")},
+	{sourceapi.Station_HARRISON, alertPhrasePattern("harrison")},
+	{sourceapi.Station_JOURNAL_SQUARE, alertPhrasePattern("journal square", "journal sq", "jsq")},
+	{sourceapi.Station_GROVE_STREET, alertPhrasePattern("grove street", "grove st")},
+	{sourceapi.Station_EXCHANGE_PLACE, alertPhrasePattern("exchange place", "exchange pl")},
+	{sourceapi.Station_WORLD_TRADE_CENTER, alertPhrasePattern("world trade center", "wtc")},
+	{sourceapi.Station_NEWPORT, alertPhrasePattern("newport")},
+	{sourceapi.Station_HOBOKEN, alertPhrasePattern("hoboken", "hob")},
+	{sourceapi.Station_CHRISTOPHER_STREET, alertPhrasePattern("christopher street", "christopher st")},
+	{sourceapi.Station_NINTH_STREET, alertPhrasePattern("9th street", "9th st")},
+	{sourceapi.Station_FOURTEENTH_STREET, alertPhrasePattern("14th street", "14th st")},
+	{sourceapi.Station_TWENTY_THIRD_STREET, alertPhrasePattern("23rd street", "23rd st")},
+	{sourceapi.Station_THIRTY_THIRD_STREET, alertPhrasePattern("33rd street", "33rd st")},
+}
+
+// Builds a regexp that matches any of the phrases as whole words in normalized alert text.
+func alertPhrasePattern(phrases ...string) *regexp.Regexp {
+	var quoted []string
+	for _, phrase := range phrases {
+		quoted = append(quoted, regexp.QuoteMeta(phrase))
+	}
+	return regexp.MustCompile(`(^|[^a-z0-9])(` + strings.Join(quoted, "|") + `)([^a-z0-9]|$)`)
+}
+
+var alertDashPattern = regexp.MustCompile(`\s*[-–—]\s*`)
+
+// Finds the stations and routes mentioned in the text of an alert.
+func findAffectedStationsAndRoutes(text string) ([]sourceapi.Station, []sourceapi.Route) {
+	text = alertDashPattern.ReplaceAllString(strings.ToLower(text), "-")
+	var routes []sourceapi.Route
+	for _, p := range alertRoutePatterns {
+		if p.pattern.MatchString(text) {
+			routes = append(routes, p.route)
+			text = p.pattern.ReplaceAllString(text, "$1 $3")
+		}
+	}
+	var stations []sourceapi.Station
+	for _, p := range alertStationPatterns {
+		if p.pattern.MatchString(text) {
+			stations = append(stations, p.station)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i] < routes[j] })
+	sort.Slice(stations, func(i, j int) bool { return stations[i] < stations[j] })
+	return stations, routes
+}
+
+// Alert causes and effects inferred from keywords in the text of an alert. The first matching keyword wins.
+// Keywords are matched as whole words, so e.g. "power" doesn't match "empowered".
+var alertCauseKeywords = []struct {
+	pattern *regexp.Regexp
+	cause   gtfs.Alert_Cause
+}{
+	{alertPhrasePattern("police"), gtfs.Alert_POLICE_ACTIVITY},
+	{alertPhrasePattern("medical"), gtfs.Alert_MEDICAL_EMERGENCY},
+	{alertPhrasePattern("sick passenger", "sick customer"), gtfs.Alert_MEDICAL_EMERGENCY},
+	{alertPhrasePattern("weather"), gtfs.Alert_WEATHER},
+	{alertPhrasePattern("construction"), gtfs.Alert_CONSTRUCTION},
+	{alertPhrasePattern("maintenance"), gtfs.Alert_MAINTENANCE},
+	{alertPhrasePattern("signal", "signals"), gtfs.Alert_TECHNICAL_PROBLEM},
+	{alertPhrasePattern("mechanical"), gtfs.Alert_TECHNICAL_PROBLEM},
+	{alertPhrasePattern("power"), gtfs.Alert_TECHNICAL_PROBLEM},
+}
+
+var alertEffectKeywords = []struct {
+	pattern *regexp.Regexp
+	effect  gtfs.Alert_Effect
+}{
+	{alertPhrasePattern("suspended", "suspension"), gtfs.Alert_NO_SERVICE},
+	{alertPhrasePattern("no service"), gtfs.Alert_NO_SERVICE},
+	{alertPhrasePattern("elevator", "elevators"), gtfs.Alert_ACCESSIBILITY_ISSUE},
+	{alertPhrasePattern("escalator", "escalators"), gtfs.Alert_ACCESSIBILITY_ISSUE},
+	{alertPhrasePattern("delay", "delays", "delayed"), gtfs.Alert_SIGNIFICANT_DELAYS},
+	{alertPhrasePattern("reduced"), gtfs.Alert_REDUCED_SERVICE},
+	{alertPhrasePattern("detour", "detours", "detoured"), gtfs.Alert_DETOUR},
+}
+
+// Build a GTFS Realtime message containing the service alerts.
+//
+// Affected stations and routes are mapped to GTFS static stop and route IDs using the static data.
+// Alerts that don't mention any particular station or route are attached to every route.
+func buildAlertsFeedMessage(clock clock.Clock, staticData staticData, alerts []ServiceAlert) *gtfs.FeedMessage {
+	var entities []*gtfs.FeedEntity
+	for _, alert := range alerts {
+		var informedEntities []*gtfs.EntitySelector
+		for _, station := range alert.Stations {
+			if stopId, ok := staticData.stationToStopId[station]; ok {
+				informedEntities = append(informedEntities, &gtfs.EntitySelector{StopId: ptr(stopId)})
+			}
+		}
+		for _, route := range alert.Routes {
+			if routeId, ok := staticData.routeToRouteId[route]; ok {
+				informedEntities = append(informedEntities, &gtfs.EntitySelector{RouteId: ptr(routeId)})
+			}
+		}
+		if len(alert.Stations) == 0 && len(alert.Routes) == 0 {
+			var routeIds []string
+			for _, routeId := range staticData.routeToRouteId {
+				routeIds = append(routeIds, routeId)
+			}
+			sort.Strings(routeIds)
+			for _, routeId := range routeIds {
+				informedEntities = append(informedEntities, &gtfs.EntitySelector{RouteId: ptr(routeId)})
+			}
+		}
+		if len(informedEntities) == 0 {
+			continue
+		}
+		gtfsAlert := &gtfs.Alert{
+			InformedEntity:  informedEntities,
+			Cause:           gtfs.Alert_UNKNOWN_CAUSE.Enum(),
+			Effect:          gtfs.Alert_UNKNOWN_EFFECT.Enum(),
+			HeaderText:      translatedString(alert.Header),
+			DescriptionText: translatedString(alert.Description),
+			Url:             translatedString(alert.Url),
+		}
+		if !alert.Start.IsZero() || !alert.End.IsZero() {
+			activePeriod := &gtfs.TimeRange{}
+			if !alert.Start.IsZero() {
+				activePeriod.Start = ptr(uint64(alert.Start.Unix()))
+			}
+			if !alert.End.IsZero() {
+				activePeriod.End = ptr(uint64(alert.End.Unix()))
+			}
+			gtfsAlert.ActivePeriod = []*gtfs.TimeRange{activePeriod}
+		}
+		text := strings.ToLower(alert.Header + "\n" + alert.Description)
+		for _, k := range alertCauseKeywords {
+			if k.pattern.MatchString(text) {
+				gtfsAlert.Cause = k.cause.Enum()
+				break
+			}
+		}
+		for _, k := range alertEffectKeywords {
+			if k.pattern.MatchString(text) {
+				gtfsAlert.Effect = k.effect.Enum()
+				break
+			}
+		}
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:    ptr(fmt.Sprintf("alert_%s", alert.Id)),
+			Alert: gtfsAlert,
+		})
+	}
+	return newFeedMessage(clock, entities)
+}
+
+func translatedString(s string) *gtfs.TranslatedString {
+	if s == "" {
+		return nil
+	}
+	return &gtfs.TranslatedString{
+		Translation: []*gtfs.TranslatedString_Translation{
+			{Text: ptr(s), Language: ptr("en")},
+		},
+	}
+}
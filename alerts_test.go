@@ -0,0 +1,296 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestPaNyNjAlertSource(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	for _, tc := range []struct {
+		name       string
+		filePath   string
+		wantAlerts []ServiceAlert
+	}{
+		{
+			name:     "JSON",
+			filePath: "mock_data/alerts_01.json",
+			wantAlerts: []ServiceAlert{
+				{
+					Id:          "8a9e1f2c-1",
+					Header:      "PATH Alert: NWK-WTC Delays",
+					Description: "NWK-WTC trains are subject to delays of up to 15 minutes due to police activity at Journal Square. PATH tickets and passes are being cross-honored by NJ TRANSIT.",
+					Stations:    []sourceapi.Station{sourceapi.Station_JOURNAL_SQUARE},
+					Routes:      []sourceapi.Route{sourceapi.Route_NWK_WTC},
+					Start:       time.Date(2023, time.December, 18, 20, 30, 0, 0, est),
+				},
+				{
+					Id:          "8a9e1f2c-2",
+					Header:      "PATH Alert: Elevator Outage",
+					Description: "The street elevator at Christopher St is out of service.",
+					Stations:    []sourceapi.Station{sourceapi.Station_CHRISTOPHER_STREET},
+					Start:       time.Date(2023, time.December, 18, 8, 0, 0, 0, est),
+					End:         time.Date(2023, time.December, 20, 17, 0, 0, 0, est),
+				},
+				{
+					Id:          "8a9e1f2c-3",
+					Header:      "PATH Alert: Holiday Schedule",
+					Description: "PATH will operate on a weekend schedule on Monday, December 25.",
+				},
+			},
+		},
+		{
+			name:     "RSS",
+			filePath: "mock_data/alerts_01.xml",
+			wantAlerts: []ServiceAlert{
+				{
+					Id:          "hob33-20231218",
+					Header:      "PATH Alert: HOB-33 Service Suspended",
+					Description: "HOB-33 service is suspended due to a signal problem between Hoboken and Christopher St. Customers may use JSQ-33 via HOB trains.",
+					Url:         "https://www.panynj.gov/path/en/alerts.html",
+					Stations:    []sourceapi.Station{sourceapi.Station_HOBOKEN, sourceapi.Station_CHRISTOPHER_STREET},
+					Routes:      []sourceapi.Route{sourceapi.Route_JSQ_33_HOB, sourceapi.Route_HOB_33},
+					Start:       time.Date(2023, time.December, 18, 20, 40, 0, 0, est),
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := NewPaNyNjAlertSource(mockFileHTTPClient{FilePath: tc.filePath}, PaNyNjAlertsUrl)
+
+			gotAlerts, err := source.GetAlerts(context.Background())
+			if err != nil {
+				t.Fatalf("GetAlerts() err got=%v, want=<nil>", err)
+			}
+
+			if diff := cmp.Diff(tc.wantAlerts, gotAlerts, cmp.Comparer(func(a, b time.Time) bool {
+				return a.Equal(b)
+			})); diff != "" {
+				t.Errorf("GetAlerts() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRssAlerts_MissingGuidAndLink(t *testing.T) {
+	content := []byte(`<rss><channel>
+		<item><title>PATH Alert: HOB-33 Delays</title><pubDate>Mon, 18 Dec 2023 20:40:00 -0500</pubDate></item>
+		<item><title>PATH Alert: HOB-33 Delays</title><pubDate>Mon, 18 Dec 2023 21:10:00 -0500</pubDate></item>
+		<item><title>PATH Alert: Elevator Outage</title><pubDate>Mon, 18 Dec 2023 20:40:00 -0500</pubDate></item>
+	</channel></rss>`)
+
+	alerts, err := parseRssAlerts(content)
+	if err != nil {
+		t.Fatalf("parseRssAlerts() err got=%v, want=<nil>", err)
+	}
+	againAlerts, err := parseRssAlerts(content)
+	if err != nil {
+		t.Fatalf("parseRssAlerts() err got=%v, want=<nil>", err)
+	}
+
+	ids := map[string]bool{}
+	for i, alert := range alerts {
+		if alert.Id == "" {
+			t.Errorf("alert %d Id got=\"\", want a non-empty ID", i)
+		}
+		if ids[alert.Id] {
+			t.Errorf("alert %d Id got=%q, want an ID not used by another alert", i, alert.Id)
+		}
+		ids[alert.Id] = true
+		if againAlerts[i].Id != alert.Id {
+			t.Errorf("alert %d Id got=%q when parsed again, want=%q", i, againAlerts[i].Id, alert.Id)
+		}
+	}
+}
+
+func TestBuildAlertsFeedMessage(t *testing.T) {
+	staticData := staticData{
+		stationToStopId: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: stopIDHoboken,
+		},
+		routeToRouteId: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33:  routeID1,
+			sourceapi.Route_HOB_WTC: "routeID2",
+		},
+	}
+	alerts := []ServiceAlert{
+		{
+			Id:          "1",
+			Header:      "HOB-33 Delays",
+			Description: "HOB-33 trains are delayed due to police activity at Hoboken.",
+			Stations:    []sourceapi.Station{sourceapi.Station_HOBOKEN},
+			Routes:      []sourceapi.Route{sourceapi.Route_HOB_33},
+			Start:       makeTime(5),
+		},
+		{
+			Id:     "2",
+			Header: "Weekend schedule",
+		},
+		{
+			Id:       "3",
+			Header:   "Unknown station",
+			Stations: []sourceapi.Station{sourceapi.Station_NEWARK},
+		},
+	}
+	c := clock.NewMock()
+
+	msg := buildAlertsFeedMessage(c, staticData, alerts)
+
+	english := func(s string) *gtfsrt.TranslatedString {
+		return &gtfsrt.TranslatedString{
+			Translation: []*gtfsrt.TranslatedString_Translation{{Text: ptr(s), Language: ptr("en")}},
+		}
+	}
+	wantEntities := []*gtfsrt.FeedEntity{
+		{
+			Id: ptr("alert_1"),
+			Alert: &gtfsrt.Alert{
+				ActivePeriod: []*gtfsrt.TimeRange{{Start: ptr(uint64(makeTime(5).Unix()))}},
+				InformedEntity: []*gtfsrt.EntitySelector{
+					{StopId: ptr(stopIDHoboken)},
+					{RouteId: ptr(routeID1)},
+				},
+				Cause:           gtfsrt.Alert_POLICE_ACTIVITY.Enum(),
+				Effect:          gtfsrt.Alert_SIGNIFICANT_DELAYS.Enum(),
+				HeaderText:      english("HOB-33 Delays"),
+				DescriptionText: english("HOB-33 trains are delayed due to police activity at Hoboken."),
+			},
+		},
+		{
+			Id: ptr("alert_2"),
+			Alert: &gtfsrt.Alert{
+				InformedEntity: []*gtfsrt.EntitySelector{
+					{RouteId: ptr(routeID1)},
+					{RouteId: ptr("routeID2")},
+				},
+				Cause:      gtfsrt.Alert_UNKNOWN_CAUSE.Enum(),
+				Effect:     gtfsrt.Alert_UNKNOWN_EFFECT.Enum(),
+				HeaderText: english("Weekend schedule"),
+			},
+		},
+	}
+	if diff := cmp.Diff(wantEntities, msg.Entity, protocmp.Transform()); diff != "" {
+		t.Errorf("buildAlertsFeedMessage() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type mockFileHTTPClient struct {
+	FilePath string
+}
+
+func (m mockFileHTTPClient) Get(string) (*http.Response, error) {
+	data, err := os.ReadFile(m.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func TestPaNyNjAlertSource_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("<html>Service Unavailable</html>"))
+	}))
+	defer server.Close()
+	source := NewPaNyNjAlertSource(server.Client(), server.URL)
+
+	if _, err := source.GetAlerts(context.Background()); err == nil {
+		t.Errorf("GetAlerts() with 503 response err got=<nil>, want an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := source.GetAlerts(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAlerts() with cancelled context err got=%v, want=%v", err, context.Canceled)
+	}
+}
+
+func TestFindAffectedStationsAndRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		text         string
+		wantStations []sourceapi.Station
+		wantRoutes   []sourceapi.Route
+	}{
+		{
+			text:       "NPT-HOB trains are running on a weekend schedule.",
+			wantRoutes: []sourceapi.Route{sourceapi.Route_NPT_HOB},
+		},
+		{
+			text:       "Newport - Hoboken service is suspended.",
+			wantRoutes: []sourceapi.Route{sourceapi.Route_NPT_HOB},
+		},
+		{
+			text:         "Delays at HOB and NWK.",
+			wantStations: []sourceapi.Station{sourceapi.Station_NEWARK, sourceapi.Station_HOBOKEN},
+		},
+		{
+			text: "Customers hobnobbing at the nwkx event may experience crowding.",
+		},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			gotStations, gotRoutes := findAffectedStationsAndRoutes(tc.text)
+
+			if diff := cmp.Diff(tc.wantStations, gotStations); diff != "" {
+				t.Errorf("findAffectedStationsAndRoutes() stations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRoutes, gotRoutes); diff != "" {
+				t.Errorf("findAffectedStationsAndRoutes() routes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildAlertsFeedMessage_CauseAndEffect(t *testing.T) {
+	staticData := staticData{routeToRouteId: map[sourceapi.Route]string{sourceapi.Route_HOB_33: routeID1}}
+	for _, tc := range []struct {
+		text       string
+		wantCause  gtfsrt.Alert_Cause
+		wantEffect gtfsrt.Alert_Effect
+	}{
+		{
+			text:       "Trains are delayed due to a power outage.",
+			wantCause:  gtfsrt.Alert_TECHNICAL_PROBLEM,
+			wantEffect: gtfsrt.Alert_SIGNIFICANT_DELAYS,
+		},
+		{
+			text:       "PATH has empowered its staff to assist customers.",
+			wantCause:  gtfsrt.Alert_UNKNOWN_CAUSE,
+			wantEffect: gtfsrt.Alert_UNKNOWN_EFFECT,
+		},
+		{
+			text:       "Service is suspended because of signals.",
+			wantCause:  gtfsrt.Alert_TECHNICAL_PROBLEM,
+			wantEffect: gtfsrt.Alert_NO_SERVICE,
+		},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			msg := buildAlertsFeedMessage(clock.NewMock(), staticData, []ServiceAlert{{Id: "1", Header: tc.text}})
+
+			if len(msg.Entity) != 1 {
+				t.Fatalf("number of entities got=%d, want=1", len(msg.Entity))
+			}
+			if got := msg.Entity[0].Alert.GetCause(); got != tc.wantCause {
+				t.Errorf("cause got=%s, want=%s", got, tc.wantCause)
+			}
+			if got := msg.Entity[0].Alert.GetEffect(); got != tc.wantEffect {
+				t.Errorf("effect got=%s, want=%s", got, tc.wantEffect)
+			}
+		})
+	}
+}
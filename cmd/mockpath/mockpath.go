@@ -0,0 +1,77 @@
+// Command mockpath serves stand-ins for the PATH source APIs with synthetic data, for integration and load tests.
+//
+// The trains are generated from a fixed timetable, and faults can be injected into the responses. Run pathgtfsrt
+// against it using the --http_source_api_url, --grpc_source_api_address or --panynj_api_url flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+	// The PANYNJ API reports times in New York time, and the Docker image may not have a time zone database.
+	_ "time/tzdata"
+
+	"github.com/benbjohnson/clock"
+	pathgtfsrt "github.com/jamespfennell/path-train-gtfs-realtime"
+	"google.golang.org/grpc"
+)
+
+var httpPort = flag.Int("http_port", 8090, "the port to serve the HTTP path-data API and PANYNJ API on")
+var grpcPort = flag.Int("grpc_port", 8091, "the port to serve the gRPC path-data API on")
+var headway = flag.Duration("headway", 10*time.Minute, "the time between consecutive trains on each route in each direction")
+var travelTime = flag.Duration("travel_time", 3*time.Minute, "the time trains take to travel between consecutive stations")
+var horizon = flag.Duration("horizon", 30*time.Minute, "how far ahead each station reports trains")
+var latency = flag.Duration("latency", 0, "latency added to every response")
+var errorRate = flag.Float64("error_rate", 0, "fraction of requests that fail with a 500 over HTTP or Unavailable over gRPC")
+var malformedRate = flag.Float64("malformed_rate", 0, "fraction of HTTP responses whose JSON is truncated")
+var missingFieldsRate = flag.Float64("missing_fields_rate", 0, "fraction of trains that have one of their fields missing")
+var seed = flag.Int64("seed", 0, "seed for choosing which responses faults are injected into; if zero, the current time is used")
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	c := clock.New()
+	timetable, err := pathgtfsrt.NewTimetableSourceClient(c, *headway, *travelTime, *horizon)
+	if err != nil {
+		return err
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	faults := pathgtfsrt.MockServerFaults{
+		Latency:           *latency,
+		ErrorRate:         *errorRate,
+		MalformedRate:     *malformedRate,
+		MissingFieldsRate: *missingFieldsRate,
+	}
+	fmt.Printf("Timetable: headway %s, travel time %s, horizon %s\n", *headway, *travelTime, *horizon)
+	fmt.Printf("Faults: latency %s, error rate %v, malformed rate %v, missing fields rate %v (seed %d)\n",
+		faults.Latency, faults.ErrorRate, faults.MalformedRate, faults.MissingFieldsRate, *seed)
+	server := pathgtfsrt.NewMockServer(timetable, c, faults, *seed)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(server.UnaryInterceptor()))
+	server.Register(grpcServer)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port %d: %w", *grpcPort, err)
+	}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			fmt.Println("gRPC server stopped:", err)
+		}
+	}()
+	fmt.Println("Serving the gRPC path-data API on port", *grpcPort)
+
+	fmt.Printf("Serving the HTTP path-data API at http://localhost:%d/v1/ and the PANYNJ API at http://localhost:%d%s\n",
+		*httpPort, *httpPort, pathgtfsrt.MockPaNyNjApiPath)
+	return http.ListenAndServe(fmt.Sprintf(":%d", *httpPort), server.HttpHandler())
+}
@@ -5,16 +5,25 @@ import (
 	_ "embed"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+	// The GTFS static feed's time zone is needed to match trips to the schedule, and the Docker image
+	// may not have a time zone database.
+	_ "time/tzdata"
 
 	"github.com/benbjohnson/clock"
 	pathgtfsrt "github.com/jamespfennell/path-train-gtfs-realtime"
 	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 //go:embed index.html
@@ -25,9 +34,154 @@ var updatePeriod = flag.Duration("update_period", 5*time.Second, "how often to u
 var timeoutPeriod = flag.Duration("timeout_period", 5*time.Second, "maximum duration to wait for a response from the source API")
 var useHTTPSourceAPI = flag.Bool("use_http_source_api", false, "use the HTTP source API instead of the default gRPC API")
 var usePanynjAPI = flag.Bool("use_panynj_api", false, "use the Panynj API instead of the default path-data API")
+var httpSourceAPIURL = flag.String("http_source_api_url", "", "if set, the base URL of the HTTP path-data API, ending in /v1/; for example, the URL of a mockpath server")
+var grpcSourceAPIAddress = flag.String("grpc_source_api_address", "", "if set, the address of the gRPC path-data API; for example, the address of a mockpath server")
+var panynjAPIURL = flag.String("panynj_api_url", "", "if set, the URL of the Panynj API; for example, the URL of a mockpath server")
+var includeVehiclePositionsInFeed = flag.Bool("include_vehicle_positions_in_feed", false, "include the inferred vehicle positions in the main GTFS-RT feed")
+var enableAlerts = flag.Bool("enable_alerts", false, "retrieve service alerts from PATH's service status messages")
+var alertsURL = flag.String("alerts_url", pathgtfsrt.PaNyNjAlertsUrl, "the URL of PATH's service status messages, in JSON or RSS format")
+var includeAlertsInFeed = flag.Bool("include_alerts_in_feed", false, "include the service alerts in the main GTFS-RT feed")
+var maxDataAge = flag.Duration("max_data_age", 0, "if positive, drop the trains at a station when its data hasn't been retrieved from the source API for this long")
+var differentialHistory = flag.Duration("differential_history", 0, "if positive, serve DIFFERENTIAL updates to clients that last saw a version of the feed generated within this duration")
+var gtfsStaticLocation = flag.String("gtfs_static", "", "path or URL of a GTFS static zip file used to derive stop and route IDs")
+var usePlatformStopIDs = flag.Bool("use_platform_stop_ids", false, "use the platform stop IDs from the GTFS static feed instead of the station stop IDs; requires --gtfs_static")
+var matchScheduledTrips = flag.Bool("match_scheduled_trips", false, "match trips to the scheduled trips in the GTFS static feed; requires --gtfs_static")
+var grpcPort = flag.Int("grpc_port", 0, "if positive, serve the GTFS-RT feed over gRPC on this port")
+var sourceAPIGRPCPort = flag.Int("source_api_grpc_port", 0, "if positive, serve the path-data gRPC Stations and Routes services on this port using the configured source API")
+var failoverSourceAPIs = flag.String("failover_source_apis", "", "comma separated list of source APIs (grpc, http, panynj) to fail over between, in order of preference; overrides the other source API flags")
+var mergeSourceAPIs = flag.String("merge_source_apis", "", "comma separated list of source APIs (grpc, http, panynj) to query concurrently and merge, in order of preference; overrides the other source API flags")
+var recordDir = flag.String("record_dir", "", "if set, record all source API responses to files in this directory")
+var recordMaxFileBytes = flag.Int64("record_max_file_size", 100*1024*1024, "the maximum size in bytes of each recording file")
+var recordMaxFiles = flag.Int("record_max_files", 24, "the maximum number of recording files to keep; the oldest files are deleted")
+var panynjFile = flag.String("panynj_file", "", "read data in the PANYNJ API format (ridepath.json) from this file instead of using a source API")
+var razzaFiles = flag.String("razza_files", "", "read data in the HTTP path-data API format from one file per station instead of using a source API; a path like data/%s.json where %s is replaced by the lowercase station name")
+var replayPath = flag.String("replay", "", "comma separated list of recording files or directories to play back instead of using a source API")
+var replaySpeed = flag.Float64("replay_speed", 1, "the playback speed when replaying a recording")
+var retryMaxAttempts = flag.Int("retry_max_attempts", 3, "the maximum number of attempts of each source API request, including the first")
+var retryInitialBackoff = flag.Duration("retry_initial_backoff", 200*time.Millisecond, "the maximum backoff before the first retry of a source API request; this doubles for each subsequent retry")
+var retryMaxBackoff = flag.Duration("retry_max_backoff", 2*time.Second, "the maximum backoff between retries of a source API request")
+var breakerThreshold = flag.Int("breaker_threshold", 0, "the number of consecutive failed requests to a source API endpoint after which requests to it are stopped for the cooldown; zero disables this")
+var breakerCooldown = flag.Duration("breaker_cooldown", 30*time.Second, "how long to stop requests to a failing source API endpoint for")
+var rateLimit = flag.Float64("rate_limit", 0, "if positive, the maximum number of requests per second to each source API, including retries")
+var rateLimitBurst = flag.Int("rate_limit_burst", 20, "the number of requests to each source API that can be made in a burst above the rate limit")
+var failoverProbePeriod = flag.Duration("failover_probe_period", time.Minute, "how often to check whether a more preferred source API has recovered when failing over")
+var staticDataRefreshPeriod = flag.Duration("static_data_refresh_period", time.Hour, "if positive, how often to retrieve the stop ID of each station and the route ID of each route from the source API again")
+var readinessMaxUpdatePeriods = flag.Int("readiness_max_update_periods", 3, "the feed is reported as not ready on /readyz if no realtime data has been retrieved from the source API for this many update periods; zero disables this check")
+var degradedStart = flag.Bool("degraded_start", false, "start serving an empty feed, using built-in or GTFS static stop and route IDs, if the source API is unavailable at startup instead of exiting")
+var platformOverridesPath = flag.String("platform_overrides", "", "path of a CSV file overriding the platform stop IDs derived from the GTFS static feed")
+
+// The names of the source APIs used in the --failover_source_apis flag.
+const (
+	grpcAPI   = "grpc"
+	httpAPI   = "http"
+	panynjAPI = "panynj"
+)
+
+var sourceAPIToName = map[string]string{
+	grpcAPI:   "gRPC path-data API",
+	httpAPI:   "HTTP path-data API",
+	panynjAPI: "Panynj API",
+}
+
+// The source clients of the source APIs in use, whose state is exported in metrics.
+var resilientClients = map[string]*pathgtfsrt.ResilientSourceClient{}
+
+// Only set if failover between source APIs is enabled.
+var failoverClient *pathgtfsrt.FailoverSourceClient
+
+// Creates the source client for a source API, with retries, circuit breakers and rate limiting.
+func newSourceClient(api string) (pathgtfsrt.SourceClient, error) {
+	client, err := newBaseSourceClient(api)
+	if err != nil {
+		return nil, err
+	}
+	// Retries are only made if they start within the budget, so the budget leaves time for the last attempt
+	// to time out before the next update. If there isn't time for that, requests aren't retried.
+	maxAttempts := *retryMaxAttempts
+	budget := *updatePeriod - *timeoutPeriod
+	if budget <= 0 {
+		maxAttempts = 1
+		budget = *updatePeriod
+	}
+	resilientClient := pathgtfsrt.NewResilientSourceClient(client, clock.New(), pathgtfsrt.ResilienceConfig{
+		MaxAttempts:      maxAttempts,
+		InitialBackoff:   *retryInitialBackoff,
+		MaxBackoff:       *retryMaxBackoff,
+		Budget:           budget,
+		BreakerThreshold: *breakerThreshold,
+		BreakerCooldown:  *breakerCooldown,
+		RateLimit:        *rateLimit,
+		RateLimitBurst:   *rateLimitBurst,
+	})
+	resilientClients[api] = resilientClient
+	return resilientClient, nil
+}
+
+func newBaseSourceClient(api string) (pathgtfsrt.SourceClient, error) {
+	switch api {
+	case grpcAPI:
+		if *grpcSourceAPIAddress != "" {
+			return pathgtfsrt.NewGrpcSourceClientWithAddress(*grpcSourceAPIAddress, *timeoutPeriod)
+		}
+		return pathgtfsrt.NewGrpcSourceClient(*timeoutPeriod)
+	case httpAPI:
+		if *httpSourceAPIURL != "" {
+			return pathgtfsrt.NewHttpSourceClientWithBaseUrl(&http.Client{Timeout: *timeoutPeriod}, *httpSourceAPIURL), nil
+		}
+		return pathgtfsrt.NewHttpSourceClient(&http.Client{Timeout: *timeoutPeriod}), nil
+	case panynjAPI:
+		if *panynjAPIURL != "" {
+			return pathgtfsrt.NewPaNyNjSourceClientWithUrl(&http.Client{Timeout: *timeoutPeriod}, clock.New(), *panynjAPIURL), nil
+		}
+		return pathgtfsrt.NewPaNyNjSourceClient(&http.Client{Timeout: *timeoutPeriod}, clock.New()), nil
+	}
+	return nil, fmt.Errorf("unknown source API %q; must be one of %s, %s and %s", api, grpcAPI, httpAPI, panynjAPI)
+}
+
+// Creates the source clients for a comma separated list of source APIs. The returned function closes the clients.
+func newNamedSourceClients(apis string) ([]pathgtfsrt.NamedSourceClient, func(), error) {
+	var sources []pathgtfsrt.NamedSourceClient
+	var closers []io.Closer
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+	for _, api := range strings.Split(apis, ",") {
+		api = strings.TrimSpace(api)
+		client, err := newSourceClient(api)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if closer, ok := client.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+		sources = append(sources, pathgtfsrt.NamedSourceClient{Name: api, Client: client})
+	}
+	return sources, closeAll, nil
+}
 
 func getDataSourceApiName() string {
-	if *usePanynjAPI {
+	if *panynjFile != "" {
+		return fmt.Sprintf("Panynj API file %s", *panynjFile)
+	} else if *razzaFiles != "" {
+		return fmt.Sprintf("HTTP path-data API files %s", *razzaFiles)
+	} else if *replayPath != "" {
+		return fmt.Sprintf("replay of %s", *replayPath)
+	} else if failoverClient != nil {
+		var names []string
+		for _, api := range failoverClient.Sources() {
+			names = append(names, sourceAPIToName[api])
+		}
+		return fmt.Sprintf("%s (failover order: %s)", sourceAPIToName[failoverClient.ActiveSource()], strings.Join(names, ", "))
+	} else if *mergeSourceAPIs != "" {
+		var names []string
+		for _, api := range strings.Split(*mergeSourceAPIs, ",") {
+			names = append(names, sourceAPIToName[strings.TrimSpace(api)])
+		}
+		return fmt.Sprintf("merged from %s", strings.Join(names, ", "))
+	} else if *usePanynjAPI {
 		return "Panynj API"
 	} else if *useHTTPSourceAPI {
 		return "HTTP path-data API"
@@ -40,6 +194,18 @@ const (
 	minPanynjUpdatePeriod = 15 * time.Second
 )
 
+// Raises the update period to the minimum for the PANYNJ API if it is one of the source APIs. This must be called
+// before the source clients are created, as their retry budgets are based on the update period.
+func enforceMinPanynjUpdatePeriod(apis ...string) {
+	for _, api := range apis {
+		if strings.TrimSpace(api) != panynjAPI || *updatePeriod >= minPanynjUpdatePeriod {
+			continue
+		}
+		fmt.Printf("Update period too short for Panynj API; setting to %f seconds\n", minPanynjUpdatePeriod.Seconds())
+		*updatePeriod = minPanynjUpdatePeriod
+	}
+}
+
 var numUpdatesCounter = promauto.NewCounter(
 	prometheus.CounterOpts{
 		Name: "path_train_gtfsrt_num_updates",
@@ -65,6 +231,21 @@ var numTripStopTimesGauge = promauto.NewGaugeVec(
 	},
 	[]string{"stop_id", "direction"},
 )
+var activeSourceGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "path_train_gtfsrt_active_source_api",
+		Help: "Whether each source API is the one currently used, when failing over between source APIs",
+	},
+	[]string{"source_api"},
+)
+var sourceDisagreementHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "path_train_gtfsrt_source_disagreement_seconds",
+		Help:    "Difference between the predicted arrival times of trains reported by more than one source API, when merging source APIs",
+		Buckets: []float64{5, 10, 20, 30, 45, 60, 90, 120},
+	},
+	[]string{"station"},
+)
 var numRequestsCounter = promauto.NewCounterVec(
 	prometheus.CounterOpts{
 		Name: "path_train_gtfsrt_num_requests",
@@ -83,37 +264,204 @@ func main() {
 
 func run(ctx context.Context) error {
 	var sourceClient pathgtfsrt.SourceClient
-	if *usePanynjAPI {
+	if *failoverSourceAPIs != "" && *mergeSourceAPIs != "" {
+		return fmt.Errorf("--failover_source_apis and --merge_source_apis can't be used together")
+	}
+	if *panynjFile != "" {
+		fmt.Println("Source API: PANYNJ file", *panynjFile)
+		sourceClient = pathgtfsrt.NewPaNyNjFileSourceClient(*panynjFile, clock.New())
+	} else if *razzaFiles != "" {
+		fmt.Println("Source API: path-data HTTP files", *razzaFiles)
+		fileClient, err := pathgtfsrt.NewHttpFileSourceClient(*razzaFiles, clock.New())
+		if err != nil {
+			return err
+		}
+		sourceClient = fileClient
+	} else if *replayPath != "" {
+		replayClient, err := pathgtfsrt.NewReplaySourceClient(clock.New(), *replaySpeed, strings.Split(*replayPath, ",")...)
+		if err != nil {
+			return fmt.Errorf("failed to load the recording to replay: %w", err)
+		}
+		fmt.Println("Source API: replaying", *replayPath, "starting at", replayClient.ReplayTime())
+		sourceClient = replayClient
+	} else if *failoverSourceAPIs != "" {
+		enforceMinPanynjUpdatePeriod(strings.Split(*failoverSourceAPIs, ",")...)
+		sources, closeSources, err := newNamedSourceClients(*failoverSourceAPIs)
+		if err != nil {
+			return err
+		}
+		defer closeSources()
+		fmt.Println("Source APIs, in failover order:", *failoverSourceAPIs)
+		failoverClient = pathgtfsrt.NewFailoverSourceClient(clock.New(), *failoverProbePeriod, sources...)
+		sourceClient = failoverClient
+	} else if *mergeSourceAPIs != "" {
+		enforceMinPanynjUpdatePeriod(strings.Split(*mergeSourceAPIs, ",")...)
+		sources, closeSources, err := newNamedSourceClients(*mergeSourceAPIs)
+		if err != nil {
+			return err
+		}
+		defer closeSources()
+		fmt.Println("Source APIs, merged:", *mergeSourceAPIs)
+		sourceClient = pathgtfsrt.NewMergingSourceClient(recordDisagreement, sources...)
+	} else if *usePanynjAPI {
 		fmt.Println("Source API: PANYNJ")
-		httpClient := &http.Client{Timeout: *timeoutPeriod}
-		sourceClient = pathgtfsrt.NewPaNyNjSourceClient(httpClient, clock.New())
-		// Update duration should not exceed 15 seconds
-		if *updatePeriod < minPanynjUpdatePeriod {
-			fmt.Printf("Update period too short for Panynj API; setting to %f seconds\n", minPanynjUpdatePeriod.Seconds())
-			*updatePeriod = minPanynjUpdatePeriod
+		enforceMinPanynjUpdatePeriod(panynjAPI)
+		panynjClient, err := newSourceClient(panynjAPI)
+		if err != nil {
+			return err
 		}
+		sourceClient = panynjClient
 	} else if *useHTTPSourceAPI {
 		fmt.Println("Source API: HTTP")
-		httpClient := &http.Client{Timeout: *timeoutPeriod}
-		sourceClient = pathgtfsrt.NewHttpSourceClient(httpClient)
+		httpClient, err := newSourceClient(httpAPI)
+		if err != nil {
+			return err
+		}
+		sourceClient = httpClient
 	} else {
 		fmt.Println("Source API: gRPC")
-		grpcClient, err := pathgtfsrt.NewGrpcSourceClient(*timeoutPeriod)
+		grpcClient, err := newSourceClient(grpcAPI)
 		if err != nil {
 			return err
 		}
-		defer grpcClient.Close()
+		defer grpcClient.(io.Closer).Close()
 		sourceClient = grpcClient
 	}
+	if *recordDir != "" {
+		fmt.Println("Recording source API responses to", *recordDir)
+		recordingClient, err := pathgtfsrt.NewRecordingSourceClient(sourceClient, clock.New(), *recordDir, *recordMaxFileBytes, *recordMaxFiles)
+		if err != nil {
+			return fmt.Errorf("failed to start recording: %w", err)
+		}
+		defer recordingClient.Close()
+		sourceClient = recordingClient
+	}
+
+	var gtfsStatic *pathgtfsrt.GtfsStatic
+	if *gtfsStaticLocation != "" {
+		fmt.Println("Loading GTFS static feed from", *gtfsStaticLocation)
+		var err error
+		gtfsStatic, err = pathgtfsrt.LoadGtfsStatic(*gtfsStaticLocation, &http.Client{Timeout: *timeoutPeriod})
+		if err != nil {
+			return err
+		}
+		for _, line := range gtfsStatic.MatchReport() {
+			fmt.Println(line)
+		}
+		sourceClient = pathgtfsrt.NewGtfsStaticSourceClient(sourceClient, gtfsStatic)
+	}
 
-	f, err := pathgtfsrt.NewFeed(ctx, clock.New(), *updatePeriod, sourceClient, recordUpdate)
+	var feedOpts []pathgtfsrt.FeedOption
+	if *usePlatformStopIDs {
+		if gtfsStatic == nil {
+			return fmt.Errorf("--use_platform_stop_ids requires --gtfs_static")
+		}
+		var overrides []pathgtfsrt.PlatformOverride
+		if *platformOverridesPath != "" {
+			var err error
+			overrides, err = pathgtfsrt.LoadPlatformOverrides(*platformOverridesPath)
+			if err != nil {
+				return fmt.Errorf("failed to read platform overrides: %w", err)
+			}
+		}
+		platformResolver, err := pathgtfsrt.NewPlatformResolver(gtfsStatic, overrides)
+		if err != nil {
+			return fmt.Errorf("invalid platform overrides: %w", err)
+		}
+		feedOpts = append(feedOpts, pathgtfsrt.WithPlatformResolver(platformResolver))
+	}
+	if *matchScheduledTrips {
+		if gtfsStatic == nil {
+			return fmt.Errorf("--match_scheduled_trips requires --gtfs_static")
+		}
+		feedOpts = append(feedOpts, pathgtfsrt.WithScheduleMatcher(pathgtfsrt.NewScheduleMatcher(gtfsStatic)))
+	}
+	if *degradedStart {
+		feedOpts = append(feedOpts, pathgtfsrt.WithDegradedStart(gtfsStatic))
+	}
+	if *staticDataRefreshPeriod > 0 {
+		feedOpts = append(feedOpts, pathgtfsrt.WithStaticDataRefresh(*staticDataRefreshPeriod))
+	}
+	if *maxDataAge > 0 {
+		feedOpts = append(feedOpts, pathgtfsrt.WithMaxDataAge(*maxDataAge))
+	}
+	if *differentialHistory > 0 {
+		feedOpts = append(feedOpts, pathgtfsrt.WithDifferentialUpdates(*differentialHistory))
+	}
+	if *includeVehiclePositionsInFeed {
+		feedOpts = append(feedOpts, pathgtfsrt.WithVehiclePositionsInFeed())
+	}
+	if *enableAlerts {
+		fmt.Println("Service alerts source:", *alertsURL)
+		httpClient := &http.Client{Timeout: *timeoutPeriod}
+		feedOpts = append(feedOpts, pathgtfsrt.WithAlertSource(pathgtfsrt.NewPaNyNjAlertSource(httpClient, *alertsURL)))
+		if *includeAlertsInFeed {
+			feedOpts = append(feedOpts, pathgtfsrt.WithAlertsInFeed())
+		}
+	}
+	f, err := pathgtfsrt.NewFeed(ctx, clock.New(), *updatePeriod, sourceClient, recordUpdate, feedOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to initialize feed: %s", err)
 	}
+	prometheus.MustRegister(dataAgeCollector{feed: f})
+	prometheus.MustRegister(resilienceCollector{})
+	prometheus.MustRegister(staticDataCollector{feed: f})
+
+	// The gRPC services are served on a separate port from the HTTP server; services configured with the
+	// same port share a server.
+	grpcServers := map[int]*grpc.Server{}
+	grpcServer := func(port int) *grpc.Server {
+		if _, ok := grpcServers[port]; !ok {
+			grpcServers[port] = grpc.NewServer()
+		}
+		return grpcServers[port]
+	}
+	if *grpcPort > 0 {
+		fmt.Println("Serving the GTFS-RT feed over gRPC on port", *grpcPort)
+		pathgtfsrt.NewFeedServer(f).Register(grpcServer(*grpcPort))
+	}
+	if *sourceAPIGRPCPort > 0 {
+		fmt.Println("Serving the path-data gRPC API on port", *sourceAPIGRPCPort)
+		pathgtfsrt.NewSourceServer(f.SourceSnapshot()).Register(grpcServer(*sourceAPIGRPCPort))
+	}
+	for port, server := range grpcServers {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return fmt.Errorf("failed to listen on gRPC port %d: %w", port, err)
+		}
+		server := server
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				fmt.Println("gRPC server stopped:", err)
+			}
+		}()
+	}
 
 	http.HandleFunc("/", rootHandler)
 	http.Handle("/gtfsrt", promhttp.InstrumentHandlerCounter(numRequestsCounter, f))
+	http.Handle("/vehicles", f.VehiclesHandler())
+	http.Handle("/alerts", f.AlertsHandler())
+	http.Handle("/stream", f.StreamHandler())
+	http.Handle("/api/v1/stations/", f.DeparturesHandler())
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", healthzHandler)
+	http.Handle("/readyz", readyzHandler(f))
+	http.Handle("/status", f.StatusHandler(getDataSourceApiName, *readinessMaxUpdatePeriods))
+	if *grpcPort > 0 {
+		// The gRPC-gateway forwards requests to the FeedService served above.
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", *grpcPort), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return fmt.Errorf("failed to connect the gRPC-gateway to the FeedService: %w", err)
+		}
+		defer conn.Close()
+		gateway, err := pathgtfsrt.NewFeedGatewayHandler(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("failed to create the gRPC-gateway: %w", err)
+		}
+		fmt.Println("Serving the GTFS-RT feed over the gRPC-gateway at /v1/feed")
+		http.Handle("/v1/feed", gateway)
+		http.Handle("/v1/feed/", gateway)
+	}
 
 	return http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
 }
@@ -127,6 +475,23 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 		*timeoutPeriod)
 }
 
+// The liveness check: the process is up and serving HTTP requests.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// The readiness check: the feed is using static data from the source API and has current realtime data.
+func readyzHandler(f *pathgtfsrt.Feed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Ready(*readinessMaxUpdatePeriods) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
 func recordUpdate(msg *gtfs.FeedMessage, errs []error) {
 	numTripStopTimesGauge.Reset()
 	for _, entity := range msg.GetEntity() {
@@ -142,4 +507,134 @@ func recordUpdate(msg *gtfs.FeedMessage, errs []error) {
 	numUpdatesCounter.Inc()
 	numRequestErrs.Add(float64(len(errs)))
 	lastUpdateGauge.SetToCurrentTime()
+	if failoverClient != nil {
+		activeSource := failoverClient.ActiveSource()
+		for _, api := range failoverClient.Sources() {
+			value := 0.0
+			if api == activeSource {
+				value = 1
+			}
+			activeSourceGauge.WithLabelValues(api).Set(value)
+		}
+	}
+}
+
+var dataAgeDesc = prometheus.NewDesc(
+	"path_train_gtfsrt_station_data_age_seconds",
+	"Time since the trains at each station were last retrieved from the source API",
+	[]string{"station"}, nil,
+)
+
+// Exports the age of the data for each station, which is computed when the metrics are scraped.
+type dataAgeCollector struct {
+	feed *pathgtfsrt.Feed
+}
+
+func (c dataAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dataAgeDesc
+}
+
+func (c dataAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	for station, age := range c.feed.DataAges() {
+		ch <- prometheus.MustNewConstMetric(dataAgeDesc, prometheus.GaugeValue, age.Seconds(), station.String())
+	}
+}
+
+var (
+	breakerStateDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_source_circuit_breaker_state",
+		"State of the circuit breaker of each source API endpoint: 0 closed, 1 half open (trying a request) and 2 open (not sending requests)",
+		[]string{"source_api", "endpoint"}, nil,
+	)
+	retriesDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_source_retries",
+		"Number of retried source API requests",
+		[]string{"source_api"}, nil,
+	)
+	rateLimitedDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_source_rate_limited",
+		"Number of source API requests delayed or failed by the rate limiter",
+		[]string{"source_api"}, nil,
+	)
+	breakerRejectionsDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_source_circuit_breaker_rejections",
+		"Number of source API requests not sent because a circuit breaker was open",
+		[]string{"source_api"}, nil,
+	)
+)
+
+// Exports the state of the retries, circuit breakers and rate limiters of the source APIs.
+type resilienceCollector struct{}
+
+func (c resilienceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+	ch <- retriesDesc
+	ch <- rateLimitedDesc
+	ch <- breakerRejectionsDesc
+}
+
+func (c resilienceCollector) Collect(ch chan<- prometheus.Metric) {
+	for api, client := range resilientClients {
+		for endpoint, state := range client.BreakerStates() {
+			ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(state), api, endpoint)
+		}
+		stats := client.Stats()
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(stats.Retries), api)
+		ch <- prometheus.MustNewConstMetric(rateLimitedDesc, prometheus.CounterValue, float64(stats.RateLimited), api)
+		ch <- prometheus.MustNewConstMetric(breakerRejectionsDesc, prometheus.CounterValue, float64(stats.Rejected), api)
+	}
+}
+
+var (
+	staticDataLastRefreshedDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_static_data_last_refreshed_timestamp_seconds",
+		"When the static data was last retrieved from the source API",
+		nil, nil,
+	)
+	staticDataFailuresDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_static_data_refresh_failures",
+		"Number of failed attempts to retrieve the static data from the source API",
+		nil, nil,
+	)
+	staticDataChangesDesc = prometheus.NewDesc(
+		"path_train_gtfsrt_static_data_changes",
+		"Number of stations and routes added, removed or whose ID changed when refreshing the static data",
+		[]string{"mapping", "change"}, nil,
+	)
+)
+
+type staticDataCollector struct {
+	feed *pathgtfsrt.Feed
+}
+
+func (c staticDataCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- staticDataLastRefreshedDesc
+	ch <- staticDataFailuresDesc
+	ch <- staticDataChangesDesc
+}
+
+func (c staticDataCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.feed.StaticDataStats()
+	if !stats.LastRefreshed.IsZero() {
+		ch <- prometheus.MustNewConstMetric(staticDataLastRefreshedDesc, prometheus.GaugeValue, float64(stats.LastRefreshed.Unix()))
+	}
+	ch <- prometheus.MustNewConstMetric(staticDataFailuresDesc, prometheus.CounterValue, float64(stats.Failures))
+	for _, change := range []struct {
+		mapping string
+		change  string
+		count   uint64
+	}{
+		{"station", "added", stats.StationsAdded},
+		{"station", "removed", stats.StationsRemoved},
+		{"station", "changed", stats.StationsChanged},
+		{"route", "added", stats.RoutesAdded},
+		{"route", "removed", stats.RoutesRemoved},
+		{"route", "changed", stats.RoutesChanged},
+	} {
+		ch <- prometheus.MustNewConstMetric(staticDataChangesDesc, prometheus.CounterValue, float64(change.count), change.mapping, change.change)
+	}
+}
+
+func recordDisagreement(station sourceapi.Station, disagreement time.Duration) {
+	sourceDisagreementHistogram.WithLabelValues(strings.ToLower(station.String())).Observe(disagreement.Seconds())
 }
@@ -0,0 +1,49 @@
+package pathgtfsrt
+
+import (
+	"context"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// WithDegradedStart makes the feed start even if the source API is unavailable.
+//
+// If the static data can't be retrieved from the source API, the feed uses the built-in stop and route IDs,
+// replaced by the IDs matched in the GTFS static feed if one is provided, and retries retrieving the static data
+// on each update. Errors getting realtime data during the first update don't cause NewFeed to fail; the feed is
+// then empty until the source API recovers. Use Ready to check whether the feed is fully operational.
+func WithDegradedStart(gtfsStatic *GtfsStatic) FeedOption {
+	return func(o *feedOptions) {
+		o.degradedStart = true
+		o.fallbackGtfsStatic = gtfsStatic
+	}
+}
+
+// The static data used when it can't be retrieved from the source API.
+type fallbackStaticDataSource struct {
+	gtfsStatic *GtfsStatic
+}
+
+func newFallbackStaticDataSource(gtfsStatic *GtfsStatic) fallbackStaticDataSource {
+	return fallbackStaticDataSource{gtfsStatic: gtfsStatic}
+}
+
+func (s fallbackStaticDataSource) GetStationToStopId(context.Context) (map[sourceapi.Station]string, error) {
+	result := copyMap(sourceStationToGtfsStopId)
+	if s.gtfsStatic != nil {
+		for station, stopId := range s.gtfsStatic.stationToStopId {
+			result[station] = stopId
+		}
+	}
+	return result, nil
+}
+
+func (s fallbackStaticDataSource) GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error) {
+	result := copyMap(sourceRouteToGtfsRouteId)
+	if s.gtfsStatic != nil {
+		for route, routeId := range s.gtfsStatic.routeToRouteId {
+			result[route] = routeId
+		}
+	}
+	return result, nil
+}
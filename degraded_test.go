@@ -0,0 +1,126 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// A source client all of whose requests fail while it is down.
+type unavailableSourceClient struct {
+	mockSourceClient
+	down bool
+}
+
+func (u *unavailableSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	if u.down {
+		return nil, errors.New("source is down")
+	}
+	return u.mockSourceClient.GetStationToStopId(ctx)
+}
+
+func (u *unavailableSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	if u.down {
+		return nil, errors.New("source is down")
+	}
+	return u.mockSourceClient.GetRouteToRouteId(ctx)
+}
+
+func (u *unavailableSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	if u.down {
+		return nil, errors.New("source is down")
+	}
+	return u.mockSourceClient.GetTrainsAtStation(ctx, station)
+}
+
+func newUnavailableSourceClient() *unavailableSourceClient {
+	return &unavailableSourceClient{
+		mockSourceClient: mockSourceClient{
+			stationToStopID: map[sourceapi.Station]string{
+				sourceapi.Station_HOBOKEN: stopIDHoboken,
+			},
+			routeToRouteID: map[sourceapi.Route]string{
+				sourceapi.Route_HOB_33: routeID1,
+			},
+			stationToTrains: map[sourceapi.Station][]Train{
+				sourceapi.Station_HOBOKEN: {
+					sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 15, 0),
+				},
+			},
+		},
+		down: true,
+	}
+}
+
+func TestNewFeed_SourceUnavailable(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	_, err := NewFeed(context.Background(), c, 5*time.Second, newUnavailableSourceClient(), func(*gtfsrt.FeedMessage, []error) {})
+	if err == nil {
+		t.Errorf("NewFeed() err got=<nil>, want an error")
+	}
+}
+
+func TestNewFeed_DegradedStart(t *testing.T) {
+	client := newUnavailableSourceClient()
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	numEntities := make(chan int, 1)
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, client, func(msg *gtfsrt.FeedMessage, _ []error) {
+		numEntities <- len(msg.Entity)
+	}, WithDegradedStart(nil))
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	if got := <-numEntities; got != 0 {
+		t.Errorf("number of entities got=%d, want=0", got)
+	}
+	if feed.Ready(0) {
+		t.Errorf("Ready() got=true, want=false")
+	}
+
+	// The static data is retrieved in its own goroutine, so the update at the same time may still use the
+	// fallback static data.
+	client.down = false
+	c.Add(5 * time.Second)
+	<-numEntities
+	waitForStaticDataStats(t, feed, func(stats StaticDataStats) bool { return !stats.LastRefreshed.IsZero() })
+	c.Add(5 * time.Second)
+	if got := <-numEntities; got != 1 {
+		t.Errorf("number of entities got=%d, want=1", got)
+	}
+	if !feed.Ready(0) {
+		t.Errorf("Ready() got=false, want=true")
+	}
+}
+
+func TestFallbackStaticDataSource(t *testing.T) {
+	gtfsStatic := &GtfsStatic{
+		stationToStopId: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: "static-hoboken"},
+		routeToRouteId:  map[sourceapi.Route]string{sourceapi.Route_HOB_33: "static-route"},
+	}
+	source := newFallbackStaticDataSource(gtfsStatic)
+
+	stationToStopId, _ := source.GetStationToStopId(context.Background())
+	if got := stationToStopId[sourceapi.Station_HOBOKEN]; got != "static-hoboken" {
+		t.Errorf("stop ID of HOBOKEN got=%q, want=%q", got, "static-hoboken")
+	}
+	if got, want := stationToStopId[sourceapi.Station_NEWARK], sourceStationToGtfsStopId[sourceapi.Station_NEWARK]; got != want {
+		t.Errorf("stop ID of NEWARK got=%q, want=%q", got, want)
+	}
+	routeToRouteId, _ := source.GetRouteToRouteId(context.Background())
+	if got := routeToRouteId[sourceapi.Route_HOB_33]; got != "static-route" {
+		t.Errorf("route ID of HOB_33 got=%q, want=%q", got, "static-route")
+	}
+	if got, want := routeToRouteId[sourceapi.Route_NWK_WTC], sourceRouteToGtfsRouteId[sourceapi.Route_NWK_WTC]; got != want {
+		t.Errorf("route ID of NWK_WTC got=%q, want=%q", got, want)
+	}
+	if sourceStationToGtfsStopId[sourceapi.Station_HOBOKEN] == "static-hoboken" {
+		t.Errorf("built-in mapping was modified")
+	}
+}
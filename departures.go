@@ -0,0 +1,187 @@
+package pathgtfsrt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// StationDepartures is the response of the departures API.
+type StationDepartures struct {
+	// The station's key in the URL, for example hoboken.
+	Station     string `json:"station"`
+	StationName string `json:"station_name"`
+	// The GTFS static stop ID of the station.
+	StopId string `json:"stop_id"`
+	// When the feed was last updated.
+	LastUpdated time.Time   `json:"last_updated"`
+	Departures  []Departure `json:"departures"`
+}
+
+// Departure is an upcoming train at a station.
+type Departure struct {
+	// The trip ID used for the train in the GTFS realtime feed.
+	TripId    string `json:"trip_id"`
+	Headsign  string `json:"headsign"`
+	RouteId   string `json:"route_id"`
+	RouteName string `json:"route_name"`
+	// The colors of the line as hex codes like #4D92FB. Trains running on more than one line have more than one color.
+	Colors []string `json:"colors"`
+	// Either TO_NY or TO_NJ.
+	Direction   string `json:"direction"`
+	DirectionId uint32 `json:"direction_id"`
+	// The GTFS static stop ID the train arrives at; this is the platform stop ID if platforms are resolved.
+	StopId string `json:"stop_id"`
+	// The status reported by the source API, if any: ON_TIME, ARRIVING_NOW or DELAYED.
+	Status           string    `json:"status,omitempty"`
+	ProjectedArrival time.Time `json:"projected_arrival"`
+	// Rounded down, and zero for trains arriving now.
+	MinutesToArrival int `json:"minutes_to_arrival"`
+	// When the source API last updated the prediction for the train.
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// The departures at each station, built from a single update of the feed.
+type departures struct {
+	updated             time.Time
+	keyToStation        map[string]sourceapi.Station
+	stationToStopId     map[sourceapi.Station]string
+	stationToDepartures map[sourceapi.Station][]Departure
+}
+
+// Returns the key used for a station in the departures API URL; for example, hoboken.
+func stationKey(station sourceapi.Station) string {
+	return strings.ToLower(station.String())
+}
+
+// Builds the departures at each station from the trips built from a snapshot of the current data.
+func buildDepartures(clock clock.Clock, staticData staticData, trips []*trip) departures {
+	d := departures{
+		updated:             clock.Now(),
+		keyToStation:        map[string]sourceapi.Station{},
+		stationToStopId:     staticData.stationToStopId,
+		stationToDepartures: map[sourceapi.Station][]Departure{},
+	}
+	for _, station := range staticData.stations {
+		d.keyToStation[stationKey(station)] = station
+		d.keyToStation[staticData.stationToStopId[station]] = station
+	}
+	for _, trip := range trips {
+		tripDescriptor := buildTripDescriptor(staticData, trip)
+		metadata := sourceRouteToMetadata[trip.route]
+		for _, stop := range trip.stops {
+			train := stop.train
+			departure := Departure{
+				TripId:           tripDescriptor.GetTripId(),
+				Headsign:         train.Headsign,
+				RouteId:          tripDescriptor.GetRouteId(),
+				RouteName:        train.RouteDisplayName,
+				Colors:           train.LineColors,
+				Direction:        trip.direction.String(),
+				DirectionId:      tripDescriptor.GetDirectionId(),
+				StopId:           staticData.stopId(stop.station, trip.route, trip.direction),
+				ProjectedArrival: train.ProjectedArrival.AsTime(),
+				LastUpdated:      train.LastUpdated.AsTime(),
+			}
+			if departure.Headsign == "" {
+				departure.Headsign = defaultHeadsign(trip.route, trip.direction)
+			}
+			if departure.RouteName == "" {
+				departure.RouteName = metadata.longName
+			}
+			if len(departure.Colors) == 0 && metadata.color != "" {
+				departure.Colors = []string{"#" + metadata.color}
+			}
+			if train.Status != sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_STATUS_UNSPECIFIED {
+				departure.Status = train.Status.String()
+			}
+			d.stationToDepartures[stop.station] = append(d.stationToDepartures[stop.station], departure)
+		}
+	}
+	for _, stationDepartures := range d.stationToDepartures {
+		sort.SliceStable(stationDepartures, func(i, j int) bool {
+			return stationDepartures[i].ProjectedArrival.Before(stationDepartures[j].ProjectedArrival)
+		})
+	}
+	return d
+}
+
+// Returns the name of the last station of the route in the direction, or the empty string if the route is unknown.
+func defaultHeadsign(route sourceapi.Route, direction sourceapi.Direction) string {
+	stations := stationsInDirection(route, direction)
+	if len(stations) == 0 {
+		return ""
+	}
+	return sourceStationToName[stations[len(stations)-1]]
+}
+
+// Returns the departures at the station with the provided key, which is either the station key in the URL or
+// its GTFS static stop ID. Trains that have already left the station are omitted.
+func (d departures) get(key string, now time.Time) (StationDepartures, bool) {
+	station, ok := d.keyToStation[key]
+	if !ok {
+		return StationDepartures{}, false
+	}
+	result := StationDepartures{
+		Station:     stationKey(station),
+		StationName: sourceStationToName[station],
+		StopId:      d.stationToStopId[station],
+		LastUpdated: d.updated,
+		Departures:  []Departure{},
+	}
+	for _, departure := range d.stationToDepartures[station] {
+		untilArrival := departure.ProjectedArrival.Sub(now)
+		if untilArrival < -stoppedAtWindow {
+			continue
+		}
+		if untilArrival > 0 {
+			departure.MinutesToArrival = int(untilArrival / time.Minute)
+		}
+		result.Departures = append(result.Departures, departure)
+	}
+	return result, true
+}
+
+// DeparturesHandler returns a handler that responds to requests for /api/v1/stations/{station}/departures
+// with the upcoming trains at the station in JSON.
+//
+// The station is identified by its lowercase source API name, like hoboken or fourteenth_street,
+// or by its GTFS static stop ID.
+func (f *Feed) DeparturesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-1] != "departures" {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown path %q", r.URL.Path))
+			return
+		}
+		key := parts[len(parts)-2]
+		stationDepartures, ok := f.get().departures.get(key, f.clock.Now())
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown station %q", key))
+			return
+		}
+		b, err := json.MarshalIndent(stationDepartures, "", "  ")
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(f.updatePeriod.Seconds())))
+		writeBytes(w, b)
+	})
+}
+
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	b, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	writeBytes(w, b)
+}
@@ -0,0 +1,138 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestDeparturesHandler(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	hobokenTrain := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 7, 0)
+	hobokenTrain.Headsign = "33rd Street"
+	hobokenTrain.RouteDisplayName = "HOB - 33rd"
+	hobokenTrain.LineColors = []string{"#4D92FB"}
+	hobokenTrain.Status = sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_DELAYED
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN:           stopIDHoboken,
+			sourceapi.Station_FOURTEENTH_STREET: stopID14St,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {
+				hobokenTrain,
+				sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NJ, -2, 0),
+			},
+			sourceapi.Station_FOURTEENTH_STREET: {
+				sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NJ, 0, 0),
+			},
+		},
+	}
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, &client, func(*gtfsrt.FeedMessage, []error) {})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		wantStatus int
+		want       StationDepartures
+	}{
+		{
+			name:       "station key",
+			path:       "/api/v1/stations/hoboken/departures",
+			wantStatus: 200,
+			want: StationDepartures{
+				Station:     "hoboken",
+				StationName: "Hoboken",
+				StopId:      stopIDHoboken,
+				LastUpdated: makeTime(0),
+				Departures: []Departure{
+					{
+						TripId:           "HOB_33_TO_NY_1677406020",
+						Headsign:         "33rd Street",
+						RouteId:          routeID1,
+						RouteName:        "HOB - 33rd",
+						Colors:           []string{"#4D92FB"},
+						Direction:        "TO_NY",
+						DirectionId:      1,
+						StopId:           stopIDHoboken,
+						Status:           "DELAYED",
+						ProjectedArrival: makeTime(7),
+						MinutesToArrival: 7,
+						LastUpdated:      makeTime(0),
+					},
+				},
+			},
+		},
+		{
+			name:       "stop ID and default route metadata",
+			path:       "/api/v1/stations/" + stopID14St + "/departures",
+			wantStatus: 200,
+			want: StationDepartures{
+				Station:     "fourteenth_street",
+				StationName: "14th Street",
+				StopId:      stopID14St,
+				LastUpdated: makeTime(0),
+				Departures: []Departure{
+					{
+						TripId:           "HOB_33_TO_NJ_1677405600",
+						Headsign:         "Hoboken",
+						RouteId:          routeID1,
+						RouteName:        "Hoboken - 33rd Street",
+						Colors:           []string{"#4D92FB"},
+						Direction:        "TO_NJ",
+						StopId:           stopID14St,
+						ProjectedArrival: makeTime(0),
+						LastUpdated:      makeTime(0),
+					},
+				},
+			},
+		},
+		{
+			name:       "unknown station",
+			path:       "/api/v1/stations/grand_central/departures",
+			wantStatus: 404,
+		},
+		{
+			name:       "unknown path",
+			path:       "/api/v1/stations/hoboken/arrivals",
+			wantStatus: 404,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			feed.DeparturesHandler().ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status code got=%d, want=%d", w.Code, tc.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type got=%q, want=%q", got, "application/json")
+			}
+			if tc.wantStatus != 200 {
+				return
+			}
+			var got StationDepartures
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal() err got=%v, want=<nil>", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
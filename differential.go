@@ -0,0 +1,150 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/protobuf/proto"
+)
+
+// LastSeenTimestampHeader is the HTTP request header in which clients of a differential feed send the header
+// timestamp of the last feed message they received.
+const LastSeenTimestampHeader = "X-Last-Seen-Timestamp"
+
+// differentialHistory stores recent versions of a feed so that clients can be sent only the entities that changed
+// since the version they last saw.
+//
+// A history is not modified when a version is added, so that it can be stored with the version of the feed whose
+// history it is, and clients never see a differential message for a version that is newer than the full feed.
+type differentialHistory struct {
+	maxAge time.Duration
+	// Ordered from oldest to newest.
+	snapshots []feedSnapshot
+	mutex     sync.Mutex
+	// Map from the timestamp of the version a client last saw to the differential message for that client.
+	cache map[uint64]renderedFeed
+}
+
+type feedSnapshot struct {
+	timestamp uint64
+	// Entity IDs in the order they appear in the feed.
+	ids []string
+	// Map from entity ID to the serialized entity.
+	entities map[string][]byte
+}
+
+func newDifferentialHistory(maxAge time.Duration) *differentialHistory {
+	return &differentialHistory{maxAge: maxAge, cache: map[uint64]renderedFeed{}}
+}
+
+// Returns the history with a new version of the feed added, and the versions that are older than the maximum age
+// discarded.
+func (h *differentialHistory) add(msg *gtfs.FeedMessage) *differentialHistory {
+	snapshot := feedSnapshot{
+		timestamp: msg.GetHeader().GetTimestamp(),
+		entities:  map[string][]byte{},
+	}
+	for _, entity := range msg.Entity {
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(entity)
+		if err != nil {
+			continue
+		}
+		snapshot.ids = append(snapshot.ids, entity.GetId())
+		snapshot.entities[entity.GetId()] = b
+	}
+	snapshots := h.snapshots
+	// Versions with the same timestamp can't be distinguished by clients, so only the latest is kept.
+	if n := len(snapshots); n > 0 && snapshots[n-1].timestamp == snapshot.timestamp {
+		snapshots = snapshots[:n-1]
+	}
+	minTimestamp := int64(snapshot.timestamp) - int64(h.maxAge.Seconds())
+	for len(snapshots) > 0 && int64(snapshots[0].timestamp) < minTimestamp {
+		snapshots = snapshots[1:]
+	}
+	newHistory := newDifferentialHistory(h.maxAge)
+	// The snapshots are copied so that appending doesn't modify the backing array shared with this history.
+	newHistory.snapshots = append(append(make([]feedSnapshot, 0, len(snapshots)+1), snapshots...), snapshot)
+	return newHistory
+}
+
+// Returns the differential message that brings a client that last saw the version with the given timestamp up
+// to date. If that version is no longer, or was never, in the history, false is returned and the client should
+// be sent the full dataset.
+func (h *differentialHistory) get(lastSeen uint64) (renderedFeed, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if b, ok := h.cache[lastSeen]; ok {
+		return b, true
+	}
+	if len(h.snapshots) == 0 {
+		return renderedFeed{}, false
+	}
+	var previous *feedSnapshot
+	for i := range h.snapshots {
+		if h.snapshots[i].timestamp == lastSeen {
+			previous = &h.snapshots[i]
+			break
+		}
+	}
+	if previous == nil {
+		return renderedFeed{}, false
+	}
+	current := h.snapshots[len(h.snapshots)-1]
+	diff := renderFeed(buildDifferentialFeedMessage(*previous, current))
+	// Each client that last saw a different version gets a different response.
+	diff.version = fmt.Sprintf("%d-%d", lastSeen, current.timestamp)
+	h.cache[lastSeen] = diff
+	return diff, true
+}
+
+// Builds a differential message containing the entities that were added or changed between the previous and
+// current versions, and deletions for the entities that were removed.
+func buildDifferentialFeedMessage(previous, current feedSnapshot) *gtfs.FeedMessage {
+	msg := &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: ptr("0.2"),
+			Incrementality:      gtfs.FeedHeader_DIFFERENTIAL.Enum(),
+			Timestamp:           ptr(current.timestamp),
+		},
+	}
+	for _, id := range current.ids {
+		b := current.entities[id]
+		if previousB, ok := previous.entities[id]; ok && bytes.Equal(b, previousB) {
+			continue
+		}
+		entity := &gtfs.FeedEntity{}
+		if err := proto.Unmarshal(b, entity); err != nil {
+			continue
+		}
+		msg.Entity = append(msg.Entity, entity)
+	}
+	for _, id := range previous.ids {
+		if _, ok := current.entities[id]; ok {
+			continue
+		}
+		msg.Entity = append(msg.Entity, &gtfs.FeedEntity{
+			Id:        ptr(id),
+			IsDeleted: ptr(true),
+		})
+	}
+	return msg
+}
+
+// Returns the differential data for the client that sent the request, if the request has a last seen
+// timestamp header and the corresponding version is in the history.
+func (h *differentialHistory) getForRequest(r *http.Request) (renderedFeed, bool) {
+	header := r.Header.Get(LastSeenTimestampHeader)
+	if header == "" {
+		return renderedFeed{}, false
+	}
+	lastSeen, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return renderedFeed{}, false
+	}
+	return h.get(lastSeen)
+}
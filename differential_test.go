@@ -0,0 +1,129 @@
+package pathgtfsrt
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestDifferentialHistory(t *testing.T) {
+	entity := func(id string, stopID string) *gtfsrt.FeedEntity {
+		return &gtfsrt.FeedEntity{
+			Id: ptr(id),
+			TripUpdate: &gtfsrt.TripUpdate{
+				Trip:           &gtfsrt.TripDescriptor{TripId: ptr(id)},
+				StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{{StopId: ptr(stopID)}},
+			},
+		}
+	}
+	message := func(timestamp uint64, entities ...*gtfsrt.FeedEntity) *gtfsrt.FeedMessage {
+		return &gtfsrt.FeedMessage{
+			Header: &gtfsrt.FeedHeader{
+				GtfsRealtimeVersion: ptr("0.2"),
+				Incrementality:      gtfsrt.FeedHeader_FULL_DATASET.Enum(),
+				Timestamp:           ptr(timestamp),
+			},
+			Entity: entities,
+		}
+	}
+	differentialMessage := func(timestamp uint64, entities ...*gtfsrt.FeedEntity) *gtfsrt.FeedMessage {
+		msg := message(timestamp, entities...)
+		msg.Header.Incrementality = gtfsrt.FeedHeader_DIFFERENTIAL.Enum()
+		return msg
+	}
+	history := newDifferentialHistory(time.Minute)
+	history = history.add(message(100, entity("a", stopID14St), entity("b", stopID14St)))
+	history = history.add(message(105, entity("a", stopIDHoboken), entity("c", stopID14St)))
+	history = history.add(message(110, entity("a", stopIDHoboken), entity("c", stopID14St), entity("d", stopID14St)))
+
+	for _, tc := range []struct {
+		name     string
+		lastSeen uint64
+		wantMsg  *gtfsrt.FeedMessage
+	}{
+		{
+			name:     "changed, added and removed entities",
+			lastSeen: 100,
+			wantMsg: differentialMessage(110,
+				entity("a", stopIDHoboken),
+				entity("c", stopID14St),
+				entity("d", stopID14St),
+				&gtfsrt.FeedEntity{Id: ptr("b"), IsDeleted: ptr(true)},
+			),
+		},
+		{
+			name:     "added entity",
+			lastSeen: 105,
+			wantMsg:  differentialMessage(110, entity("d", stopID14St)),
+		},
+		{
+			name:     "up to date",
+			lastSeen: 110,
+			wantMsg:  differentialMessage(110),
+		},
+		{
+			name:     "unknown version",
+			lastSeen: 103,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Run twice to exercise the cache
+			for i := 0; i < 2; i++ {
+				got, ok := history.get(tc.lastSeen)
+
+				if ok != (tc.wantMsg != nil) {
+					t.Fatalf("get() ok got=%t, want=%t", ok, tc.wantMsg != nil)
+				}
+				if !ok {
+					return
+				}
+				gotMsg := &gtfsrt.FeedMessage{}
+				if err := proto.Unmarshal(got.get(formatProto), gotMsg); err != nil {
+					t.Fatalf("proto.Unmarshal() err got=%v, want=<nil>", err)
+				}
+				if diff := cmp.Diff(tc.wantMsg, gotMsg, protocmp.Transform()); diff != "" {
+					t.Errorf("get() mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+
+	t.Run("too far behind", func(t *testing.T) {
+		previous := history
+		history = history.add(message(170, entity("a", stopIDHoboken)))
+
+		// The previous history is unchanged, so it stays consistent with the previous version of the feed.
+		if _, ok := previous.get(105); !ok {
+			t.Errorf("previous get() ok got=false, want=true")
+		}
+		if _, ok := history.get(105); ok {
+			t.Errorf("get() ok got=true, want=false")
+		}
+		if _, ok := history.get(110); !ok {
+			t.Errorf("get() ok got=false, want=true")
+		}
+	})
+
+	t.Run("request header", func(t *testing.T) {
+		for _, tc := range []struct {
+			header string
+			wantOk bool
+		}{
+			{header: "170", wantOk: true},
+			{header: "", wantOk: false},
+			{header: "yesterday", wantOk: false},
+		} {
+			r := httptest.NewRequest("GET", "/gtfsrt", nil)
+			r.Header.Set(LastSeenTimestampHeader, tc.header)
+
+			if _, ok := history.getForRequest(r); ok != tc.wantOk {
+				t.Errorf("getForRequest(%q) ok got=%t, want=%t", tc.header, ok, tc.wantOk)
+			}
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// The number of consecutive failed requests to the active source after which the failover source client
+// switches to the next source.
+const failoverErrorThreshold = 3
+
+// NamedSourceClient is a source client along with a name used in logs and metrics, for source clients that
+// combine several sources.
+type NamedSourceClient struct {
+	Name   string
+	Client SourceClient
+}
+
+// FailoverSourceClient is a source client that gets data from the first healthy source in an ordered list of sources.
+//
+// Each request is sent to the active source. If that fails, the request is retried with the following sources
+// in order, and the first successful response is returned. After failoverErrorThreshold consecutive failures the
+// active source is considered unhealthy and the client switches to the next source.
+//
+// While a source other than the first is active, the client periodically probes the sources before it by sending
+// them a request first. If a probe succeeds, the client fails back to that source.
+type FailoverSourceClient struct {
+	sources     []NamedSourceClient
+	clock       clock.Clock
+	probePeriod time.Duration
+
+	mutex sync.Mutex
+	// The number of consecutive failed requests to each source.
+	consecutiveErrs []int
+	active          int
+	lastProbe       time.Time
+}
+
+// NewFailoverSourceClient creates a new failover source client.
+//
+// The sources are in order of preference. The probe period is how often the client checks whether a more preferred
+// source has recovered.
+func NewFailoverSourceClient(clock clock.Clock, probePeriod time.Duration, sources ...NamedSourceClient) *FailoverSourceClient {
+	return &FailoverSourceClient{
+		sources:         sources,
+		clock:           clock,
+		probePeriod:     probePeriod,
+		consecutiveErrs: make([]int, len(sources)),
+	}
+}
+
+// ActiveSource returns the name of the source currently in use.
+func (c *FailoverSourceClient) ActiveSource() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sources[c.active].Name
+}
+
+// Sources returns the names of the sources in order of preference.
+func (c *FailoverSourceClient) Sources() []string {
+	var names []string
+	for _, source := range c.sources {
+		names = append(names, source.Name)
+	}
+	return names
+}
+
+func (c *FailoverSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	return failoverRequest(ctx, c, func(client SourceClient) (map[sourceapi.Station]string, error) {
+		return client.GetStationToStopId(ctx)
+	})
+}
+
+func (c *FailoverSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	return failoverRequest(ctx, c, func(client SourceClient) (map[sourceapi.Route]string, error) {
+		return client.GetRouteToRouteId(ctx)
+	})
+}
+
+func (c *FailoverSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	return failoverRequest(ctx, c, func(client SourceClient) ([]Train, error) {
+		return client.GetTrainsAtStation(ctx, station)
+	})
+}
+
+// Sends the request to the sources in the order returned by requestOrder until one succeeds.
+func failoverRequest[T any](ctx context.Context, c *FailoverSourceClient, request func(SourceClient) (T, error)) (T, error) {
+	var errs []error
+	for _, i := range c.requestOrder() {
+		result, err := request(c.sources[i].Client)
+		c.recordResult(i, err)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", c.sources[i].Name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("all sources failed: %v", errs)
+}
+
+// Returns the indices of the sources in the order they should be tried for a request.
+//
+// This is the active source followed by the less preferred sources, and then the more preferred sources.
+// If a probe is due, the more preferred sources are tried first instead.
+func (c *FailoverSourceClient) requestOrder() []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var preferred, rest []int
+	for i := range c.sources {
+		if i < c.active {
+			preferred = append(preferred, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	if len(preferred) > 0 && c.clock.Since(c.lastProbe) >= c.probePeriod {
+		c.lastProbe = c.clock.Now()
+		return append(preferred, rest...)
+	}
+	return append(rest, preferred...)
+}
+
+func (c *FailoverSourceClient) recordResult(i int, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err == nil {
+		c.consecutiveErrs[i] = 0
+		if i < c.active {
+			fmt.Printf("Source %s has recovered; failing back from %s\n", c.sources[i].Name, c.sources[c.active].Name)
+			c.active = i
+		}
+		return
+	}
+	c.consecutiveErrs[i]++
+	if i != c.active || c.consecutiveErrs[i] < failoverErrorThreshold || len(c.sources) == 1 {
+		return
+	}
+	next := (i + 1) % len(c.sources)
+	for j := 1; j < len(c.sources); j++ {
+		candidate := (i + j) % len(c.sources)
+		if c.consecutiveErrs[candidate] < failoverErrorThreshold {
+			next = candidate
+			break
+		}
+	}
+	fmt.Printf("Source %s failed %d times in a row; failing over to %s\n", c.sources[i].Name, c.consecutiveErrs[i], c.sources[next].Name)
+	c.active = next
+	c.lastProbe = c.clock.Now()
+}
@@ -0,0 +1,150 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// A source client whose train requests fail while it is down.
+type flakySourceClient struct {
+	mockSourceClient
+	down     bool
+	requests int
+}
+
+func (f *flakySourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	f.requests++
+	if f.down {
+		return nil, errors.New("source is down")
+	}
+	return f.mockSourceClient.GetTrainsAtStation(ctx, station)
+}
+
+func TestFailoverSourceClient(t *testing.T) {
+	newSource := func(route sourceapi.Route) *flakySourceClient {
+		return &flakySourceClient{
+			mockSourceClient: mockSourceClient{
+				stationToTrains: map[sourceapi.Station][]Train{
+					sourceapi.Station_HOBOKEN: {sourceTrain(route, sourceapi.Direction_TO_NY, 5, 0)},
+				},
+			},
+		}
+	}
+	primary := newSource(sourceapi.Route_HOB_33)
+	secondary := newSource(sourceapi.Route_HOB_WTC)
+	c := clock.NewMock()
+	client := NewFailoverSourceClient(c, time.Minute,
+		NamedSourceClient{Name: "primary", Client: primary},
+		NamedSourceClient{Name: "secondary", Client: secondary},
+	)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name                  string
+		advance               time.Duration
+		primaryDown           bool
+		secondaryDown         bool
+		wantRoute             sourceapi.Route
+		wantErr               bool
+		wantActive            string
+		wantPrimaryRequests   int
+		wantSecondaryRequests int
+	}{
+		{
+			name:                "healthy",
+			wantRoute:           sourceapi.Route_HOB_33,
+			wantActive:          "primary",
+			wantPrimaryRequests: 1,
+		},
+		{
+			name:                  "first error retries with next source",
+			primaryDown:           true,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "primary",
+			wantPrimaryRequests:   1,
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                  "second error",
+			primaryDown:           true,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "primary",
+			wantPrimaryRequests:   1,
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                  "third error fails over",
+			primaryDown:           true,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "secondary",
+			wantPrimaryRequests:   1,
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                  "failed over source is used directly",
+			primaryDown:           true,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "secondary",
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                  "unsuccessful probe",
+			advance:               time.Minute,
+			primaryDown:           true,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "secondary",
+			wantPrimaryRequests:   1,
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                  "no probe before the probe period",
+			advance:               30 * time.Second,
+			wantRoute:             sourceapi.Route_HOB_WTC,
+			wantActive:            "secondary",
+			wantSecondaryRequests: 1,
+		},
+		{
+			name:                "successful probe fails back",
+			advance:             30 * time.Second,
+			wantRoute:           sourceapi.Route_HOB_33,
+			wantActive:          "primary",
+			wantPrimaryRequests: 1,
+		},
+		{
+			name:                  "all sources down",
+			primaryDown:           true,
+			secondaryDown:         true,
+			wantErr:               true,
+			wantActive:            "primary",
+			wantPrimaryRequests:   1,
+			wantSecondaryRequests: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c.Add(tc.advance)
+			primary.down, secondary.down = tc.primaryDown, tc.secondaryDown
+			primary.requests, secondary.requests = 0, 0
+
+			trains, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GetTrainsAtStation() err got=%v, wantErr=%t", err, tc.wantErr)
+			}
+			if !tc.wantErr && (len(trains) != 1 || trains[0].Route != tc.wantRoute) {
+				t.Errorf("GetTrainsAtStation() got=%v, want a train of route %s", trains, tc.wantRoute)
+			}
+			if got := client.ActiveSource(); got != tc.wantActive {
+				t.Errorf("ActiveSource() got=%s, want=%s", got, tc.wantActive)
+			}
+			if primary.requests != tc.wantPrimaryRequests || secondary.requests != tc.wantSecondaryRequests {
+				t.Errorf("requests got=(%d, %d), want=(%d, %d)",
+					primary.requests, secondary.requests, tc.wantPrimaryRequests, tc.wantSecondaryRequests)
+			}
+		})
+	}
+}
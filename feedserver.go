@@ -0,0 +1,61 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	feedapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/feedapi"
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// FeedServer implements the gRPC FeedService defined in proto/feedapi/feed.proto, which serves the GTFS realtime
+// data of a feed.
+type FeedServer struct {
+	feed *Feed
+}
+
+func NewFeedServer(feed *Feed) *FeedServer {
+	return &FeedServer{feed: feed}
+}
+
+// Register registers the FeedService on the gRPC server.
+func (s *FeedServer) Register(server *grpc.Server) {
+	feedapi.RegisterFeedServiceServer(server, s)
+}
+
+// GetFeed returns the most recent GTFS realtime message.
+func (s *FeedServer) GetFeed(context.Context, *emptypb.Empty) (*gtfs.FeedMessage, error) {
+	msg := s.feed.broadcaster.latestMessage()
+	if msg == nil {
+		return nil, status.Error(codes.Unavailable, "the feed has not been built yet")
+	}
+	return msg, nil
+}
+
+// StreamFeed sends the GTFS realtime message built in each update to the client, starting with the most recent message,
+// until the client cancels the call.
+func (s *FeedServer) StreamFeed(_ *emptypb.Empty, stream feedapi.FeedService_StreamFeedServer) error {
+	for msg := range s.feed.Subscribe(stream.Context()) {
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return stream.Context().Err()
+}
+
+// NewFeedGatewayHandler returns an HTTP handler that serves the FeedService as JSON using the gRPC-gateway.
+//
+// GetFeed is served at /v1/feed and StreamFeed at /v1/feed/stream, which writes one JSON object per line.
+// Requests are forwarded to the FeedService over the gRPC connection, which is not closed by the handler.
+func NewFeedGatewayHandler(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux()
+	if err := feedapi.RegisterFeedServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
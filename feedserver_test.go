@@ -0,0 +1,116 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	feedapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/feedapi"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestFeedServer(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	updateSignal := make(chan struct{}, 1)
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: stopIDHoboken,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed, err := NewFeed(ctx, c, 5*time.Second, &client, func(*gtfsrt.FeedMessage, []error) {
+		updateSignal <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	<-updateSignal
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	NewFeedServer(feed).Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() err got=%v, want=<nil>", err)
+	}
+	defer conn.Close()
+	feedClient := feedapi.NewFeedServiceClient(conn)
+
+	checkMessage := func(t *testing.T, msg *gtfsrt.FeedMessage, wantTime time.Time) {
+		if got, want := msg.GetHeader().GetTimestamp(), uint64(wantTime.Unix()); got != want {
+			t.Errorf("message timestamp got=%d, want=%d", got, want)
+		}
+		if got := len(msg.GetEntity()); got != 1 {
+			t.Errorf("len(message entities) got=%d, want=1", got)
+		}
+	}
+
+	t.Run("GetFeed", func(t *testing.T) {
+		msg, err := feedClient.GetFeed(ctx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatalf("GetFeed() err got=%v, want=<nil>", err)
+		}
+		checkMessage(t, msg, c.Now())
+	})
+
+	t.Run("GetFeed gateway", func(t *testing.T) {
+		handler, err := NewFeedGatewayHandler(ctx, conn)
+		if err != nil {
+			t.Fatalf("NewFeedGatewayHandler() err got=%v, want=<nil>", err)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/feed", nil))
+
+		if w.Code != 200 {
+			t.Fatalf("status code got=%d, want=200", w.Code)
+		}
+		msg := &gtfsrt.FeedMessage{}
+		if err := protojson.Unmarshal(w.Body.Bytes(), msg); err != nil {
+			t.Fatalf("protojson.Unmarshal() err got=%v, want=<nil>", err)
+		}
+		checkMessage(t, msg, c.Now())
+	})
+
+	t.Run("StreamFeed", func(t *testing.T) {
+		streamCtx, cancelStream := context.WithCancel(ctx)
+		defer cancelStream()
+		stream, err := feedClient.StreamFeed(streamCtx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatalf("StreamFeed() err got=%v, want=<nil>", err)
+		}
+		receive := func() *gtfsrt.FeedMessage {
+			msg, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Recv() err got=%v, want=<nil>", err)
+			}
+			return msg
+		}
+
+		checkMessage(t, receive(), c.Now())
+		c.Add(5 * time.Second)
+		<-updateSignal
+		checkMessage(t, receive(), c.Now())
+	})
+}
@@ -0,0 +1,190 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FileHttpClient is an HttpClient that reads files from disk instead of making requests, so that the source clients
+// can be run without network access.
+//
+// Each URL is mapped to a file path by a function. Files are cached, and re-read when their modification time or
+// size changes.
+type FileHttpClient struct {
+	urlToPath func(url string) string
+
+	mutex sync.Mutex
+	cache map[string]cachedFile
+}
+
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+func NewFileHttpClient(urlToPath func(url string) string) *FileHttpClient {
+	return &FileHttpClient{urlToPath: urlToPath, cache: map[string]cachedFile{}}
+}
+
+func (c *FileHttpClient) Get(url string) (*http.Response, error) {
+	path := c.urlToPath(url)
+	if path == "" {
+		return nil, fmt.Errorf("no file for URL %s", url)
+	}
+	data, err := c.read(path)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func (c *FileHttpClient) read(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, ok := c.cache[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.cache[path]; ok {
+		fmt.Println("Reloaded", path)
+	}
+	c.cache[path] = cachedFile{modTime: info.ModTime(), size: info.Size(), data: data}
+	return data, nil
+}
+
+// PaNyNjFileSourceClient is a source client that reads data in the format of the PANYNJ JSON API (ridepath.json)
+// from a file. The file is re-read when it changes.
+//
+// The times in the returned trains are shifted so that the data appears current; see shiftTrainsToNow.
+type PaNyNjFileSourceClient struct {
+	*PaNyNjClient
+}
+
+// NewPaNyNjFileSourceClient creates a new file source client for the PANYNJ JSON API format.
+func NewPaNyNjFileSourceClient(path string, clock clock.Clock) *PaNyNjFileSourceClient {
+	return &PaNyNjFileSourceClient{NewPaNyNjSourceClient(NewFileHttpClient(func(string) string { return path }), clock)}
+}
+
+func (client *PaNyNjFileSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	trains, err := client.PaNyNjClient.GetTrainsAtStation(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	return shiftTrainsToNow(trains, client.clock.Now()), nil
+}
+
+// HttpFileSourceClient is a source client that reads data in the format of the Razza HTTP API from one file per
+// station. The files are re-read when they change.
+//
+// The stations are those whose files exist when the client is created. The stop and route IDs are the built-in
+// ones used by the PANYNJ source client. The times in the returned trains are shifted so that the data appears
+// current; see shiftTrainsToNow.
+type HttpFileSourceClient struct {
+	*HttpSourceClient
+	clock           clock.Clock
+	stationToStopId map[sourceapi.Station]string
+}
+
+// NewHttpFileSourceClient creates a new file source client for the Razza HTTP API format.
+//
+// The path template contains a %s, which is replaced by the lowercase source API name of each station;
+// for example, mock_data/source_http_%s.json.
+func NewHttpFileSourceClient(pathTemplate string, clock clock.Clock) (*HttpFileSourceClient, error) {
+	if strings.Count(pathTemplate, "%s") != 1 {
+		return nil, fmt.Errorf("invalid path template %q; must contain exactly one %%s", pathTemplate)
+	}
+	stationToPath := map[string]string{}
+	stationToStopId := map[sourceapi.Station]string{}
+	for station, stopId := range sourceStationToGtfsStopId {
+		stationAsString := strings.ToLower(sourceapi.Station_name[int32(station)])
+		path := fmt.Sprintf(pathTemplate, stationAsString)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		stationToPath[stationAsString] = path
+		stationToStopId[station] = stopId
+	}
+	if len(stationToStopId) == 0 {
+		return nil, fmt.Errorf("no station files match %q", pathTemplate)
+	}
+	httpClient := NewFileHttpClient(func(url string) string {
+		for stationAsString, path := range stationToPath {
+			if url == apiBaseUrl+fmt.Sprintf(apiRealtimeEndpoint, stationAsString) {
+				return path
+			}
+		}
+		return ""
+	})
+	return &HttpFileSourceClient{
+		HttpSourceClient: NewHttpSourceClient(httpClient),
+		clock:            clock,
+		stationToStopId:  stationToStopId,
+	}, nil
+}
+
+func (client *HttpFileSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	trains, err := client.HttpSourceClient.GetTrainsAtStation(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	return shiftTrainsToNow(trains, client.clock.Now()), nil
+}
+
+func (client *HttpFileSourceClient) GetStationToStopId(context.Context) (map[sourceapi.Station]string, error) {
+	return client.stationToStopId, nil
+}
+
+func (client *HttpFileSourceClient) GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error) {
+	return sourceRouteToGtfsRouteId, nil
+}
+
+// Shifts the times in the trains so that the most recently updated train was last updated now.
+//
+// Files are typically fixtures saved in the past, so without this all of their trains would have already left
+// and would be dropped from the feed. The times of the trains relative to each other are unchanged.
+func shiftTrainsToNow(trains []Train, now time.Time) []Train {
+	var latest time.Time
+	for _, train := range trains {
+		if train.LastUpdated != nil && train.LastUpdated.AsTime().After(latest) {
+			latest = train.LastUpdated.AsTime()
+		}
+	}
+	if latest.IsZero() {
+		return trains
+	}
+	shift := now.Sub(latest)
+	shiftTimestamp := func(t *timestamppb.Timestamp) *timestamppb.Timestamp {
+		if t == nil {
+			return nil
+		}
+		return timestamppb.New(t.AsTime().Add(shift))
+	}
+	for _, train := range trains {
+		train.ProjectedArrival = shiftTimestamp(train.ProjectedArrival)
+		train.LastUpdated = shiftTimestamp(train.LastUpdated)
+	}
+	return trains
+}
@@ -0,0 +1,156 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestHttpFileSourceClient(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hoboken.json")
+	copyFile(t, "mock_data/source_http_hoboken.json", path, time.Unix(0, 0))
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	wantTrains := func(fixture string) []Train {
+		trains, err := NewHttpSourceClient(mockFileHTTPClient{FilePath: fixture}).GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+		if err != nil {
+			t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+		}
+		return shiftTrainsToNow(trains, c.Now())
+	}
+
+	client, err := NewHttpFileSourceClient(filepath.Join(dir, "%s.json"), c)
+	if err != nil {
+		t.Fatalf("NewHttpFileSourceClient() err got=%v, want=<nil>", err)
+	}
+
+	stationToStopId, err := client.GetStationToStopId(ctx)
+	if err != nil {
+		t.Fatalf("GetStationToStopId() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: "26730"}, stationToStopId); diff != "" {
+		t.Errorf("GetStationToStopId() mismatch (-want +got):\n%s", diff)
+	}
+	trains, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(wantTrains("mock_data/source_http_hoboken.json"), trains, protocmp.Transform()); diff != "" {
+		t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+	}
+
+	// The file is re-read when it changes.
+	copyFile(t, "mock_data/source_http_newport.json", path, time.Unix(100, 0))
+	trains, err = client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(wantTrains("mock_data/source_http_newport.json"), trains, protocmp.Transform()); diff != "" {
+		t.Errorf("GetTrainsAtStation() after change mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewHttpFileSourceClient(filepath.Join(dir, "missing_%s.json"), c); err == nil {
+		t.Errorf("NewHttpFileSourceClient() err got=<nil>, want error when no station files exist")
+	}
+}
+
+func TestPaNyNjFileSourceClient(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	want, err := NewPaNyNjSourceClient(mockFileHTTPClient{FilePath: "mock_data/ridepath_01.json"}, c).GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	want = shiftTrainsToNow(want, c.Now())
+
+	got, err := NewPaNyNjFileSourceClient("mock_data/ridepath_01.json", c).GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestShiftTrainsToNow(t *testing.T) {
+	trains := []Train{
+		sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0),
+		sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 10, 2),
+	}
+
+	got := shiftTrainsToNow(trains, makeTime(60))
+
+	want := []Train{
+		sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 63, 58),
+		sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 68, 60),
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("shiftTrainsToNow() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewFeed_FileSourceClients(t *testing.T) {
+	// The fixtures were saved in December 2023, so without shifting their times all of the trains would have left.
+	c := clock.NewMock()
+	c.Set(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC))
+	httpFileClient, err := NewHttpFileSourceClient("mock_data/source_http_%s.json", c)
+	if err != nil {
+		t.Fatalf("NewHttpFileSourceClient() err got=%v, want=<nil>", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		client SourceClient
+	}{
+		{
+			name:   "PANYNJ",
+			client: NewPaNyNjFileSourceClient("mock_data/ridepath_01.json", c),
+		},
+		{
+			name:   "HTTP",
+			client: httpFileClient,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			numEntities := make(chan int, 1)
+
+			_, err := NewFeed(ctx, c, 5*time.Second, tc.client, func(msg *gtfsrt.FeedMessage, _ []error) {
+				numEntities <- len(msg.Entity)
+			})
+
+			if err != nil {
+				t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+			}
+			if got := <-numEntities; got == 0 {
+				t.Errorf("number of entities got=0, want some")
+			}
+		})
+	}
+}
+
+func copyFile(t *testing.T, src, dst string, modTime time.Time) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("os.ReadFile() err got=%v, want=<nil>", err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatalf("os.WriteFile() err got=%v, want=<nil>", err)
+	}
+	if err := os.Chtimes(dst, modTime, modTime); err != nil {
+		t.Fatalf("os.Chtimes() err got=%v, want=<nil>", err)
+	}
+}
@@ -0,0 +1,243 @@
+package pathgtfsrt
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+)
+
+// The query parameters that filter the feed.
+var filterQueryParams = []string{"stop_id", "route_id", "direction_id", "max_arrivals", "horizon"}
+
+// The maximum number of distinct filtered views cached between updates.
+const maxCachedFilteredViews = 1000
+
+// feedFilter describes a subset of a feed requested using query parameters.
+type feedFilter struct {
+	// If non-empty, only arrivals at these stops are kept. Station stop IDs also match the platforms of the station.
+	stopIds map[string]bool
+	// If non-empty, only trips of these routes are kept.
+	routeIds map[string]bool
+	// If non-nil, only trips in this direction are kept.
+	directionId *uint32
+	// If positive, only the trips with the earliest arrivals are kept.
+	maxArrivals int
+	// If positive, only arrivals within this duration of the feed timestamp are kept.
+	horizon time.Duration
+	// Uniquely identifies the filter, and is used as the cache key.
+	key string
+}
+
+// Parses the filter query parameters. The second return value is false if the query has no filter parameters.
+//
+// The stop_id and route_id parameters may be repeated or contain comma separated lists of IDs.
+// The horizon is a duration like 30m.
+func parseFeedFilter(query url.Values) (feedFilter, bool, error) {
+	filter := feedFilter{
+		stopIds:  map[string]bool{},
+		routeIds: map[string]bool{},
+	}
+	canonical := url.Values{}
+	for _, param := range filterQueryParams {
+		values := query[param]
+		if len(values) == 0 {
+			continue
+		}
+		var ids []string
+		for _, value := range values {
+			for _, id := range strings.Split(value, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+		sort.Strings(ids)
+		canonical[param] = ids
+		value := strings.Join(ids, ",")
+		switch param {
+		case "stop_id":
+			for _, id := range ids {
+				filter.stopIds[id] = true
+			}
+		case "route_id":
+			for _, id := range ids {
+				filter.routeIds[id] = true
+			}
+		case "direction_id":
+			if value != "0" && value != "1" {
+				return feedFilter{}, false, fmt.Errorf("invalid direction_id %q; must be 0 or 1", value)
+			}
+			directionId := uint32(0)
+			if value == "1" {
+				directionId = 1
+			}
+			filter.directionId = &directionId
+		case "max_arrivals":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return feedFilter{}, false, fmt.Errorf("invalid max_arrivals %q; must be a positive integer", value)
+			}
+			filter.maxArrivals = n
+		case "horizon":
+			horizon, err := time.ParseDuration(value)
+			if err != nil || horizon <= 0 {
+				return feedFilter{}, false, fmt.Errorf("invalid horizon %q; must be a positive duration like 30m", value)
+			}
+			filter.horizon = horizon
+		}
+	}
+	if len(canonical) == 0 {
+		return feedFilter{}, false, nil
+	}
+	filter.key = canonical.Encode()
+	return filter, true, nil
+}
+
+// Returns whether the stop ID matches the filter.
+func (filter feedFilter) matchesStop(stopId string, stopIdToStationStopId map[string]string) bool {
+	if len(filter.stopIds) == 0 {
+		return true
+	}
+	return filter.stopIds[stopId] || filter.stopIds[stopIdToStationStopId[stopId]]
+}
+
+func (filter feedFilter) matchesTrip(trip *gtfs.TripDescriptor) bool {
+	if len(filter.routeIds) > 0 && !filter.routeIds[trip.GetRouteId()] {
+		return false
+	}
+	if filter.directionId != nil && (trip.DirectionId == nil || *trip.DirectionId != *filter.directionId) {
+		return false
+	}
+	return true
+}
+
+// Builds the subset of the feed message described by the filter.
+//
+// Trip updates keep only the stop time updates that match the filter, and are dropped if none do.
+// Vehicle positions are kept if their trip and stop match the filter. Alerts are kept if they
+// inform any matching route or stop, or are not specific to any route or stop.
+func filterFeedMessage(msg *gtfs.FeedMessage, filter feedFilter, stopIdToStationStopId map[string]string) *gtfs.FeedMessage {
+	now := int64(msg.GetHeader().GetTimestamp())
+	inHorizon := func(stopTimeUpdate *gtfs.TripUpdate_StopTimeUpdate) bool {
+		if filter.horizon <= 0 {
+			return true
+		}
+		t := stopTimeUpdate.GetArrival().GetTime()
+		return t <= now+int64(filter.horizon.Seconds())
+	}
+	type tripUpdateEntity struct {
+		entity       *gtfs.FeedEntity
+		firstArrival int64
+	}
+	var tripUpdates []tripUpdateEntity
+	var others []*gtfs.FeedEntity
+	for _, entity := range msg.Entity {
+		switch {
+		case entity.TripUpdate != nil:
+			if !filter.matchesTrip(entity.TripUpdate.Trip) {
+				continue
+			}
+			var stopTimeUpdates []*gtfs.TripUpdate_StopTimeUpdate
+			for _, stopTimeUpdate := range entity.TripUpdate.StopTimeUpdate {
+				if filter.matchesStop(stopTimeUpdate.GetStopId(), stopIdToStationStopId) && inHorizon(stopTimeUpdate) {
+					stopTimeUpdates = append(stopTimeUpdates, stopTimeUpdate)
+				}
+			}
+			if len(stopTimeUpdates) == 0 {
+				continue
+			}
+			if len(stopTimeUpdates) != len(entity.TripUpdate.StopTimeUpdate) {
+				entity = &gtfs.FeedEntity{
+					Id: entity.Id,
+					TripUpdate: &gtfs.TripUpdate{
+						Trip:           entity.TripUpdate.Trip,
+						Vehicle:        entity.TripUpdate.Vehicle,
+						StopTimeUpdate: stopTimeUpdates,
+						Timestamp:      entity.TripUpdate.Timestamp,
+						Delay:          entity.TripUpdate.Delay,
+					},
+				}
+			}
+			tripUpdates = append(tripUpdates, tripUpdateEntity{
+				entity:       entity,
+				firstArrival: stopTimeUpdates[0].GetArrival().GetTime(),
+			})
+		case entity.Vehicle != nil:
+			if filter.matchesTrip(entity.Vehicle.Trip) && filter.matchesStop(entity.Vehicle.GetStopId(), stopIdToStationStopId) {
+				others = append(others, entity)
+			}
+		case entity.Alert != nil:
+			for _, informed := range entity.Alert.InformedEntity {
+				routeMatches := informed.RouteId == nil || len(filter.routeIds) == 0 || filter.routeIds[informed.GetRouteId()]
+				stopMatches := informed.StopId == nil || filter.matchesStop(informed.GetStopId(), stopIdToStationStopId)
+				if routeMatches && stopMatches {
+					others = append(others, entity)
+					break
+				}
+			}
+		}
+	}
+	if filter.maxArrivals > 0 && len(tripUpdates) > filter.maxArrivals {
+		sort.SliceStable(tripUpdates, func(i, j int) bool {
+			return tripUpdates[i].firstArrival < tripUpdates[j].firstArrival
+		})
+		tripUpdates = tripUpdates[:filter.maxArrivals]
+	}
+	result := &gtfs.FeedMessage{Header: msg.Header}
+	for _, tripUpdate := range tripUpdates {
+		result.Entity = append(result.Entity, tripUpdate.entity)
+	}
+	result.Entity = append(result.Entity, others...)
+	return result
+}
+
+// filteredViews builds and caches filtered views of a single version of a feed.
+type filteredViews struct {
+	msg                   *gtfs.FeedMessage
+	stopIdToStationStopId map[string]string
+	mutex                 sync.Mutex
+	cache                 map[string]*filteredView
+}
+
+// A filtered view that is being or has been rendered.
+type filteredView struct {
+	// Closed once the view has been rendered.
+	done     chan struct{}
+	rendered renderedFeed
+}
+
+func newFilteredViews(msg *gtfs.FeedMessage, stopIdToStationStopId map[string]string) *filteredViews {
+	return &filteredViews{
+		msg:                   msg,
+		stopIdToStationStopId: stopIdToStationStopId,
+		cache:                 map[string]*filteredView{},
+	}
+}
+
+// Returns the filtered view. Views are rendered outside the lock, so requests for different views don't wait for
+// each other, and concurrent requests for the same view wait for a single rendering.
+func (v *filteredViews) get(filter feedFilter) renderedFeed {
+	v.mutex.Lock()
+	view, ok := v.cache[filter.key]
+	if !ok && len(v.cache) < maxCachedFilteredViews {
+		view = &filteredView{done: make(chan struct{})}
+		v.cache[filter.key] = view
+	}
+	v.mutex.Unlock()
+	if ok {
+		<-view.done
+		return view.rendered
+	}
+	rendered := renderFeed(filterFeedMessage(v.msg, filter, v.stopIdToStationStopId))
+	if view != nil {
+		view.rendered = rendered
+		close(view.done)
+	}
+	return rendered
+}
@@ -0,0 +1,236 @@
+package pathgtfsrt
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestFilterFeedMessage(t *testing.T) {
+	const stopIDHobokenPlatform = "stopIDHobokenPlatform"
+	stopIdToStationStopId := map[string]string{
+		stopID14St:            stopID14St,
+		stopIDHoboken:         stopIDHoboken,
+		stopIDHobokenPlatform: stopIDHoboken,
+	}
+	stopTimeUpdate := func(stopID string, minute int) *gtfsrt.TripUpdate_StopTimeUpdate {
+		return &gtfsrt.TripUpdate_StopTimeUpdate{
+			StopId:  ptr(stopID),
+			Arrival: &gtfsrt.TripUpdate_StopTimeEvent{Time: makeUnix(minute)},
+		}
+	}
+	tripUpdate := func(id string, routeID string, directionID uint32, stopTimeUpdates ...*gtfsrt.TripUpdate_StopTimeUpdate) *gtfsrt.FeedEntity {
+		return &gtfsrt.FeedEntity{
+			Id: ptr(id),
+			TripUpdate: &gtfsrt.TripUpdate{
+				Trip: &gtfsrt.TripDescriptor{
+					TripId:      ptr(id),
+					RouteId:     ptr(routeID),
+					DirectionId: ptr(directionID),
+				},
+				StopTimeUpdate: stopTimeUpdates,
+			},
+		}
+	}
+	alert := func(id string, informed ...*gtfsrt.EntitySelector) *gtfsrt.FeedEntity {
+		return &gtfsrt.FeedEntity{
+			Id:    ptr(id),
+			Alert: &gtfsrt.Alert{InformedEntity: informed},
+		}
+	}
+	trip1 := tripUpdate("trip1", routeID1, 1, stopTimeUpdate(stopIDHobokenPlatform, 5), stopTimeUpdate(stopID14St, 15))
+	trip2 := tripUpdate("trip2", routeID1, 0, stopTimeUpdate(stopID14St, 3), stopTimeUpdate(stopIDHoboken, 40))
+	trip3 := tripUpdate("trip3", "routeID2", 1, stopTimeUpdate(stopIDHoboken, 2))
+	vehicle1 := &gtfsrt.FeedEntity{
+		Id: ptr("vehicle_trip1"),
+		Vehicle: &gtfsrt.VehiclePosition{
+			Trip:   trip1.TripUpdate.Trip,
+			StopId: ptr(stopIDHobokenPlatform),
+		},
+	}
+	alert1 := alert("alert1", &gtfsrt.EntitySelector{RouteId: ptr("routeID2")})
+	alert2 := alert("alert2", &gtfsrt.EntitySelector{StopId: ptr(stopID14St)})
+	msg := &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{
+			GtfsRealtimeVersion: ptr("0.2"),
+			Timestamp:           ptr(uint64(makeTime(0).Unix())),
+		},
+		Entity: []*gtfsrt.FeedEntity{trip1, trip2, trip3, vehicle1, alert1, alert2},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		query        string
+		wantEntities []*gtfsrt.FeedEntity
+	}{
+		{
+			name:  "station stop ID",
+			query: "stop_id=" + stopIDHoboken,
+			wantEntities: []*gtfsrt.FeedEntity{
+				tripUpdate("trip1", routeID1, 1, stopTimeUpdate(stopIDHobokenPlatform, 5)),
+				tripUpdate("trip2", routeID1, 0, stopTimeUpdate(stopIDHoboken, 40)),
+				trip3,
+				vehicle1,
+				alert1,
+			},
+		},
+		{
+			name:  "platform stop ID",
+			query: "stop_id=" + stopIDHobokenPlatform,
+			wantEntities: []*gtfsrt.FeedEntity{
+				tripUpdate("trip1", routeID1, 1, stopTimeUpdate(stopIDHobokenPlatform, 5)),
+				vehicle1,
+				alert1,
+			},
+		},
+		{
+			name:         "route and direction",
+			query:        "route_id=" + routeID1 + "&direction_id=0",
+			wantEntities: []*gtfsrt.FeedEntity{trip2, alert2},
+		},
+		{
+			name:         "multiple routes",
+			query:        "route_id=" + routeID1 + ",routeID2&direction_id=1",
+			wantEntities: []*gtfsrt.FeedEntity{trip1, trip3, vehicle1, alert1, alert2},
+		},
+		{
+			name:  "horizon",
+			query: "horizon=10m",
+			wantEntities: []*gtfsrt.FeedEntity{
+				tripUpdate("trip1", routeID1, 1, stopTimeUpdate(stopIDHobokenPlatform, 5)),
+				tripUpdate("trip2", routeID1, 0, stopTimeUpdate(stopID14St, 3)),
+				trip3,
+				vehicle1,
+				alert1,
+				alert2,
+			},
+		},
+		{
+			name:         "max arrivals",
+			query:        "stop_id=" + stopID14St + "&max_arrivals=1",
+			wantEntities: []*gtfsrt.FeedEntity{tripUpdate("trip2", routeID1, 0, stopTimeUpdate(stopID14St, 3)), alert1, alert2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery() err got=%v, want=<nil>", err)
+			}
+			filter, filtered, err := parseFeedFilter(query)
+			if err != nil || !filtered {
+				t.Fatalf("parseFeedFilter() got=(%t, %v), want=(true, <nil>)", filtered, err)
+			}
+
+			gotMsg := filterFeedMessage(msg, filter, stopIdToStationStopId)
+
+			if diff := cmp.Diff(tc.wantEntities, gotMsg.Entity, protocmp.Transform()); diff != "" {
+				t.Errorf("filterFeedMessage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseFeedFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		query        string
+		wantFiltered bool
+		wantErr      bool
+		wantKey      string
+	}{
+		{
+			name:  "no filter",
+			query: "format=json",
+		},
+		{
+			name:         "canonical key",
+			query:        "route_id=b&stop_id=c,a&route_id=a&format=json",
+			wantFiltered: true,
+			wantKey:      "route_id=a&route_id=b&stop_id=a&stop_id=c",
+		},
+		{
+			name:    "invalid direction",
+			query:   "direction_id=2",
+			wantErr: true,
+		},
+		{
+			name:    "invalid max arrivals",
+			query:   "max_arrivals=-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid horizon",
+			query:   "horizon=soon",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery() err got=%v, want=<nil>", err)
+			}
+
+			filter, filtered, err := parseFeedFilter(query)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseFeedFilter() err got=%v, wantErr=%t", err, tc.wantErr)
+			}
+			if filtered != tc.wantFiltered {
+				t.Errorf("parseFeedFilter() filtered got=%t, want=%t", filtered, tc.wantFiltered)
+			}
+			if filter.key != tc.wantKey {
+				t.Errorf("parseFeedFilter() key got=%q, want=%q", filter.key, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestFilteredViews_Concurrent(t *testing.T) {
+	msg := &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{
+			GtfsRealtimeVersion: ptr("0.2"),
+			Timestamp:           ptr(uint64(makeTime(0).Unix())),
+		},
+		Entity: []*gtfsrt.FeedEntity{
+			{Id: ptr("alert1"), Alert: &gtfsrt.Alert{InformedEntity: []*gtfsrt.EntitySelector{{RouteId: ptr(routeID1)}}}},
+			{Id: ptr("alert2"), Alert: &gtfsrt.Alert{InformedEntity: []*gtfsrt.EntitySelector{{StopId: ptr(stopID14St)}}}},
+		},
+	}
+	stopIdToStationStopId := map[string]string{stopID14St: stopID14St}
+	views := newFilteredViews(msg, stopIdToStationStopId)
+	var filters []feedFilter
+	for _, query := range []string{"route_id=" + routeID1, "stop_id=" + stopID14St} {
+		values, _ := url.ParseQuery(query)
+		filter, _, err := parseFeedFilter(values)
+		if err != nil {
+			t.Fatalf("parseFeedFilter(%q) err got=%v, want=<nil>", query, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	var wg sync.WaitGroup
+	got := make([]renderedFeed, 20)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = views.get(filters[i%len(filters)])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, rendered := range got {
+		filter := filters[i%len(filters)]
+		want := renderFeed(filterFeedMessage(msg, filter, stopIdToStationStopId))
+		if diff := cmp.Diff(want.get(formatProto), rendered.get(formatProto)); diff != "" {
+			t.Errorf("get(%q) mismatch (-want +got):\n%s", filter.key, diff)
+		}
+	}
+	if len(views.cache) != len(filters) {
+		t.Errorf("number of cached views got=%d, want=%d", len(views.cache), len(filters))
+	}
+}
@@ -22,7 +22,13 @@ type GrpcSourceClient struct {
 }
 
 func NewGrpcSourceClient(timeoutPeriod time.Duration) (*GrpcSourceClient, error) {
-	conn, err := grpc.Dial(grpcApiUrl, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return NewGrpcSourceClientWithAddress(grpcApiUrl, timeoutPeriod)
+}
+
+// NewGrpcSourceClientWithAddress creates a new gRPC source client for an API at an address other than the Razza
+// gRPC API, such as the mock API served by cmd/mockpath.
+func NewGrpcSourceClientWithAddress(address string, timeoutPeriod time.Duration) (*GrpcSourceClient, error) {
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return nil, err
 	}
@@ -29,3 +29,36 @@ var sourceRouteToGtfsRouteId = map[sourceapi.Route]string{
 	sourceapi.Route_NWK_WTC:    "862",
 	sourceapi.Route_JSQ_33_HOB: "1024",
 }
+
+// The names of the stations, used to match stations to stops in a GTFS static feed.
+var sourceStationToName = map[sourceapi.Station]string{
+	sourceapi.Station_NEWARK:              "Newark",
+	sourceapi.Station_HARRISON:            "Harrison",
+	sourceapi.Station_JOURNAL_SQUARE:      "Journal Square",
+	sourceapi.Station_GROVE_STREET:        "Grove Street",
+	sourceapi.Station_EXCHANGE_PLACE:      "Exchange Place",
+	sourceapi.Station_WORLD_TRADE_CENTER:  "World Trade Center",
+	sourceapi.Station_NEWPORT:             "Newport",
+	sourceapi.Station_HOBOKEN:             "Hoboken",
+	sourceapi.Station_CHRISTOPHER_STREET:  "Christopher Street",
+	sourceapi.Station_NINTH_STREET:        "9th Street",
+	sourceapi.Station_FOURTEENTH_STREET:   "14th Street",
+	sourceapi.Station_TWENTY_THIRD_STREET: "23rd Street",
+	sourceapi.Station_THIRTY_THIRD_STREET: "33rd Street",
+}
+
+type routeMetadata struct {
+	shortName string
+	longName  string
+	color     string
+}
+
+// The names and colors of the routes, used to match routes to routes in a GTFS static feed.
+var sourceRouteToMetadata = map[sourceapi.Route]routeMetadata{
+	sourceapi.Route_HOB_33:     {shortName: "HOB-33", longName: "Hoboken - 33rd Street", color: "4D92FB"},
+	sourceapi.Route_HOB_WTC:    {shortName: "HOB-WTC", longName: "Hoboken - World Trade Center", color: "65C100"},
+	sourceapi.Route_JSQ_33:     {shortName: "JSQ-33", longName: "Journal Square - 33rd Street", color: "FF9900"},
+	sourceapi.Route_NWK_WTC:    {shortName: "NWK-WTC", longName: "Newark - World Trade Center", color: "D93A30"},
+	sourceapi.Route_JSQ_33_HOB: {shortName: "JSQ-33 via HOB", longName: "Journal Square - 33rd Street (via Hoboken)", color: "FF9900"},
+	sourceapi.Route_NPT_HOB:    {shortName: "NPT-HOB", longName: "Newport - Hoboken"},
+}
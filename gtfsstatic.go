@@ -0,0 +1,677 @@
+package pathgtfsrt
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// GtfsStatic contains the parts of a GTFS static feed that the feed generator uses, along with the
+// stop and route IDs of the source API stations and routes in that feed.
+type GtfsStatic struct {
+	stops  map[string]gtfsStop
+	routes map[string]gtfsRoute
+	trips  map[string]gtfsTrip
+	// Map from trip ID to the stop times of the trip, sorted by stop sequence.
+	stopTimes map[string][]gtfsStopTime
+	// The time zone of the agency, in which the times in the stop times are given.
+	location *time.Location
+	services map[string]gtfsService
+	// Map from service ID to date (in YYYYMMDD format) to whether service was added (true) or removed (false) on that date.
+	serviceExceptions map[string]map[string]bool
+
+	stationToStopId map[sourceapi.Station]string
+	routeToRouteId  map[sourceapi.Route]string
+	// Map from GTFS static direction ID to the direction of trips with that direction ID.
+	directionIdToDirection map[string]sourceapi.Direction
+}
+
+type gtfsStop struct {
+	id            string
+	name          string
+	locationType  string
+	parentStation string
+}
+
+type gtfsRoute struct {
+	id        string
+	shortName string
+	longName  string
+	color     string
+}
+
+type gtfsTrip struct {
+	id          string
+	routeId     string
+	serviceId   string
+	headsign    string
+	directionId string
+}
+
+type gtfsService struct {
+	// Indexed by time.Weekday.
+	days [7]bool
+	// In YYYYMMDD format, so that dates can be compared as strings.
+	startDate string
+	endDate   string
+}
+
+type gtfsStopTime struct {
+	stopId       string
+	stopSequence int
+	// Seconds after the start of the service day. May exceed 24 hours for trips running past midnight.
+	arrivalTime int
+	// False if the arrival time is neither in the feed nor could be interpolated.
+	hasArrivalTime bool
+}
+
+// LoadGtfsStatic loads a GTFS static feed from a zip file at a local path or an HTTP(S) URL,
+// and matches the source API stations and routes to stops and routes in the feed.
+func LoadGtfsStatic(location string, httpClient HttpClient) (*GtfsStatic, error) {
+	var b []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		b, err = getGtfsStaticContent(httpClient, location)
+	} else {
+		b, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GTFS static feed from %s: %w", location, err)
+	}
+	return parseGtfsStatic(b)
+}
+
+// Get the raw bytes of the GTFS static feed at a URL.
+func getGtfsStaticContent(httpClient HttpClient, url string) (bytes []byte, err error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closingErr := resp.Body.Close()
+		if err == nil {
+			err = closingErr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GTFS static URL returned status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseGtfsStatic(b []byte) (*GtfsStatic, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, file := range zipReader.File {
+		files[path.Base(file.Name)] = file
+	}
+	s := &GtfsStatic{
+		stops:     map[string]gtfsStop{},
+		routes:    map[string]gtfsRoute{},
+		trips:     map[string]gtfsTrip{},
+		stopTimes: map[string][]gtfsStopTime{},
+		location:  time.UTC,
+		services:  map[string]gtfsService{},
+
+		serviceExceptions: map[string]map[string]bool{},
+	}
+	err = readGtfsFile(files, "agency.txt", func(row map[string]string) error {
+		location, err := time.LoadLocation(row["agency_timezone"])
+		if err != nil {
+			return fmt.Errorf("invalid agency_timezone %q: %w", row["agency_timezone"], err)
+		}
+		s.location = location
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readGtfsFile(files, "stops.txt", func(row map[string]string) error {
+		s.stops[row["stop_id"]] = gtfsStop{
+			id:            row["stop_id"],
+			name:          row["stop_name"],
+			locationType:  row["location_type"],
+			parentStation: row["parent_station"],
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readGtfsFile(files, "routes.txt", func(row map[string]string) error {
+		s.routes[row["route_id"]] = gtfsRoute{
+			id:        row["route_id"],
+			shortName: row["route_short_name"],
+			longName:  row["route_long_name"],
+			color:     row["route_color"],
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readGtfsFile(files, "trips.txt", func(row map[string]string) error {
+		s.trips[row["trip_id"]] = gtfsTrip{
+			id:          row["trip_id"],
+			routeId:     row["route_id"],
+			serviceId:   row["service_id"],
+			headsign:    row["trip_headsign"],
+			directionId: row["direction_id"],
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readGtfsFile(files, "stop_times.txt", func(row map[string]string) error {
+		stopSequence, err := strconv.Atoi(row["stop_sequence"])
+		if err != nil {
+			return fmt.Errorf("invalid stop_sequence %q: %w", row["stop_sequence"], err)
+		}
+		stopTime := gtfsStopTime{
+			stopId:       row["stop_id"],
+			stopSequence: stopSequence,
+		}
+		// Times are optional for stops that aren't timepoints; they are interpolated below.
+		if strings.TrimSpace(row["arrival_time"]) != "" {
+			stopTime.arrivalTime, err = parseGtfsTime(row["arrival_time"])
+			if err != nil {
+				return err
+			}
+			stopTime.hasArrivalTime = true
+		}
+		tripId := row["trip_id"]
+		s.stopTimes[tripId] = append(s.stopTimes[tripId], stopTime)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Both calendar files are optional, but at least one of them is needed to know when trips run.
+	if _, ok := files["calendar.txt"]; ok {
+		err = readGtfsFile(files, "calendar.txt", func(row map[string]string) error {
+			var service gtfsService
+			for day, column := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+				service.days[day] = row[column] == "1"
+			}
+			service.startDate = row["start_date"]
+			service.endDate = row["end_date"]
+			s.services[row["service_id"]] = service
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := files["calendar_dates.txt"]; ok {
+		err = readGtfsFile(files, "calendar_dates.txt", func(row map[string]string) error {
+			serviceId := row["service_id"]
+			if s.serviceExceptions[serviceId] == nil {
+				s.serviceExceptions[serviceId] = map[string]bool{}
+			}
+			switch row["exception_type"] {
+			case "1":
+				s.serviceExceptions[serviceId][row["date"]] = true
+			case "2":
+				s.serviceExceptions[serviceId][row["date"]] = false
+			default:
+				return fmt.Errorf("invalid exception_type %q", row["exception_type"])
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, stopTimes := range s.stopTimes {
+		sort.Slice(stopTimes, func(i, j int) bool {
+			return stopTimes[i].stopSequence < stopTimes[j].stopSequence
+		})
+		interpolateArrivalTimes(stopTimes)
+	}
+	s.stationToStopId = matchStationsToStops(s.stops)
+	s.routeToRouteId = matchRoutesToRoutes(s.routes)
+	s.directionIdToDirection = s.inferDirections()
+	return s, nil
+}
+
+// The stations in New York. All other stations are in New Jersey.
+var newYorkStations = map[sourceapi.Station]bool{
+	sourceapi.Station_WORLD_TRADE_CENTER:  true,
+	sourceapi.Station_CHRISTOPHER_STREET:  true,
+	sourceapi.Station_NINTH_STREET:        true,
+	sourceapi.Station_FOURTEENTH_STREET:   true,
+	sourceapi.Station_TWENTY_THIRD_STREET: true,
+	sourceapi.Station_THIRTY_THIRD_STREET: true,
+}
+
+// Infers the direction of the trips with each direction ID.
+//
+// Which direction ID is used for which direction is up to the publisher of the feed, so it is inferred from the
+// trips: a trip that starts in New Jersey and ends in New York travels TO_NY, and vice versa. Each direction ID is
+// mapped to the direction of most of its trips. Direction IDs whose trips don't all travel between the states,
+// or that would map to the same direction as another direction ID, are not mapped.
+func (s *GtfsStatic) inferDirections() map[string]sourceapi.Direction {
+	stopIdToStation := map[string]sourceapi.Station{}
+	for station, stopId := range s.stationToStopId {
+		stopIdToStation[stopId] = station
+	}
+	stationOf := func(stopId string) (sourceapi.Station, bool) {
+		if station, ok := stopIdToStation[stopId]; ok {
+			return station, true
+		}
+		station, ok := stopIdToStation[s.stops[stopId].parentStation]
+		return station, ok
+	}
+	votes := map[string]map[sourceapi.Direction]int{}
+	for tripId, trip := range s.trips {
+		var stations []sourceapi.Station
+		for _, stopTime := range s.stopTimes[tripId] {
+			if station, ok := stationOf(stopTime.stopId); ok {
+				stations = append(stations, station)
+			}
+		}
+		if len(stations) < 2 {
+			continue
+		}
+		first, last := newYorkStations[stations[0]], newYorkStations[stations[len(stations)-1]]
+		var direction sourceapi.Direction
+		switch {
+		case !first && last:
+			direction = sourceapi.Direction_TO_NY
+		case first && !last:
+			direction = sourceapi.Direction_TO_NJ
+		default:
+			continue
+		}
+		if votes[trip.directionId] == nil {
+			votes[trip.directionId] = map[sourceapi.Direction]int{}
+		}
+		votes[trip.directionId][direction]++
+	}
+	result := map[string]sourceapi.Direction{}
+	directionToDirectionIds := map[sourceapi.Direction][]string{}
+	for directionId, directionToVotes := range votes {
+		toNy, toNj := directionToVotes[sourceapi.Direction_TO_NY], directionToVotes[sourceapi.Direction_TO_NJ]
+		if toNy == toNj {
+			continue
+		}
+		direction := sourceapi.Direction_TO_NY
+		if toNj > toNy {
+			direction = sourceapi.Direction_TO_NJ
+		}
+		result[directionId] = direction
+		directionToDirectionIds[direction] = append(directionToDirectionIds[direction], directionId)
+	}
+	for _, directionIds := range directionToDirectionIds {
+		if len(directionIds) > 1 {
+			for _, directionId := range directionIds {
+				delete(result, directionId)
+			}
+		}
+	}
+	return result
+}
+
+// Returns the direction of trips with the GTFS static direction ID, if it is known.
+func (s *GtfsStatic) direction(directionId string) (sourceapi.Direction, bool) {
+	direction, ok := s.directionIdToDirection[directionId]
+	return direction, ok
+}
+
+// Reads a CSV file in the GTFS static feed, invoking the callback for each row. Each row is a map from column name to value.
+func readGtfsFile(files map[string]*zip.File, name string, f func(row map[string]string) error) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("GTFS static feed is missing %s", name)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header of %s: %w", name, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		if err := f(row); err != nil {
+			return fmt.Errorf("invalid row in %s: %w", name, err)
+		}
+	}
+}
+
+// Fills in missing arrival times by interpolating linearly between the closest stops before and after with arrival
+// times. Missing times before the first or after the last stop with an arrival time are left missing.
+func interpolateArrivalTimes(stopTimes []gtfsStopTime) {
+	previous := -1
+	for i, stopTime := range stopTimes {
+		if !stopTime.hasArrivalTime {
+			continue
+		}
+		if previous >= 0 {
+			start, end := stopTimes[previous].arrivalTime, stopTime.arrivalTime
+			for j := previous + 1; j < i; j++ {
+				stopTimes[j].arrivalTime = start + (end-start)*(j-previous)/(i-previous)
+				stopTimes[j].hasArrivalTime = true
+			}
+		}
+		previous = i
+	}
+}
+
+// Parses a GTFS time of the form HH:MM:SS into a number of seconds.
+func parseGtfsTime(s string) (int, error) {
+	pieces := strings.Split(s, ":")
+	if len(pieces) != 3 {
+		return 0, fmt.Errorf("invalid GTFS time %q", s)
+	}
+	var result int
+	for _, piece := range pieces {
+		n, err := strconv.Atoi(piece)
+		if err != nil {
+			return 0, fmt.Errorf("invalid GTFS time %q", s)
+		}
+		result = result*60 + n
+	}
+	return result, nil
+}
+
+// Returns whether the service runs on the service day with the given date.
+func (s *GtfsStatic) isServiceActive(serviceId string, date time.Time) bool {
+	dateString := date.Format("20060102")
+	if active, ok := s.serviceExceptions[serviceId][dateString]; ok {
+		return active
+	}
+	service, ok := s.services[serviceId]
+	if !ok {
+		return false
+	}
+	return service.days[date.Weekday()] && service.startDate <= dateString && dateString <= service.endDate
+}
+
+// Returns the time corresponding to a number of seconds after the start of a service day.
+//
+// As specified by GTFS, times are measured from noon minus 12 hours, which differs from midnight
+// on days with daylight savings time changes.
+func (s *GtfsStatic) serviceDayTime(date time.Time, seconds int) time.Time {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, s.location)
+	return noon.Add(-12 * time.Hour).Add(time.Duration(seconds) * time.Second)
+}
+
+var gtfsNameTokenPattern = regexp.MustCompile(`[a-z0-9]+`)
+
+var gtfsNameTokenReplacements = map[string]string{
+	"st": "street",
+	"sq": "square",
+	"pl": "place",
+}
+
+// Normalizes a station or route name so that names that differ only in punctuation, case or
+// common abbreviations are equal.
+func normalizeGtfsName(name string) string {
+	var b strings.Builder
+	for _, token := range gtfsNameTokenPattern.FindAllString(strings.ToLower(name), -1) {
+		if replacement, ok := gtfsNameTokenReplacements[token]; ok {
+			token = replacement
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+func normalizeGtfsColor(color string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+}
+
+// Matches source API stations to GTFS static stations by name.
+//
+// A station matches a stop if their normalized names are equal. If there is no such stop, the station
+// matches the unique stop whose normalized name contains the station's normalized name, if there is one.
+// Only stops with location type 1 (stations) are considered, unless the feed has none.
+func matchStationsToStops(stops map[string]gtfsStop) map[sourceapi.Station]string {
+	var candidates []gtfsStop
+	for _, stop := range stops {
+		if stop.locationType == "1" {
+			candidates = append(candidates, stop)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, stop := range stops {
+			if stop.parentStation == "" {
+				candidates = append(candidates, stop)
+			}
+		}
+	}
+	result := map[sourceapi.Station]string{}
+	for station, name := range sourceStationToName {
+		normalizedName := normalizeGtfsName(name)
+		var exact, partial []string
+		for _, stop := range candidates {
+			normalizedStopName := normalizeGtfsName(stop.name)
+			if normalizedStopName == normalizedName {
+				exact = append(exact, stop.id)
+			} else if strings.Contains(normalizedStopName, normalizedName) {
+				partial = append(partial, stop.id)
+			}
+		}
+		if len(exact) == 1 {
+			result[station] = exact[0]
+		} else if len(exact) == 0 && len(partial) == 1 {
+			result[station] = partial[0]
+		}
+	}
+	return result
+}
+
+// Matches source API routes to GTFS static routes.
+//
+// Routes are matched using, in order of preference, the short name, the long name and the color.
+// A criterion is only used if it identifies a unique GTFS static route. Because several routes can have the same
+// color, the color is only used as a fallback after all routes have been matched by name: it ignores GTFS static
+// routes that another route has been matched to, and is not used if it identifies the same GTFS static route for
+// more than one source API route. Routes that can't be matched uniquely are left unmatched.
+func matchRoutesToRoutes(routes map[string]gtfsRoute) map[sourceapi.Route]string {
+	type criterion struct {
+		source func(routeMetadata) string
+		static func(gtfsRoute) string
+	}
+	// Returns the IDs of the GTFS static routes that match the source API route using the criterion.
+	findMatches := func(c criterion, metadata routeMetadata, skip map[string]bool) []string {
+		want := c.source(metadata)
+		if want == "" {
+			return nil
+		}
+		var matches []string
+		for _, staticRoute := range routes {
+			if !skip[staticRoute.id] && c.static(staticRoute) == want {
+				matches = append(matches, staticRoute.id)
+			}
+		}
+		return matches
+	}
+	nameCriteria := []criterion{
+		{
+			source: func(m routeMetadata) string { return normalizeGtfsName(m.shortName) },
+			static: func(r gtfsRoute) string { return normalizeGtfsName(r.shortName) },
+		},
+		{
+			source: func(m routeMetadata) string { return normalizeGtfsName(m.longName) },
+			static: func(r gtfsRoute) string { return normalizeGtfsName(r.longName) },
+		},
+	}
+	colorCriterion := criterion{
+		source: func(m routeMetadata) string { return normalizeGtfsColor(m.color) },
+		static: func(r gtfsRoute) string { return normalizeGtfsColor(r.color) },
+	}
+	result := map[sourceapi.Route]string{}
+	for route, metadata := range sourceRouteToMetadata {
+		for _, c := range nameCriteria {
+			if matches := findMatches(c, metadata, nil); len(matches) == 1 {
+				result[route] = matches[0]
+				break
+			}
+		}
+	}
+	matchedRouteIds := map[string]bool{}
+	for _, routeId := range result {
+		matchedRouteIds[routeId] = true
+	}
+	routeIdToColorMatches := map[string][]sourceapi.Route{}
+	for route, metadata := range sourceRouteToMetadata {
+		if _, ok := result[route]; ok {
+			continue
+		}
+		if matches := findMatches(colorCriterion, metadata, matchedRouteIds); len(matches) == 1 {
+			routeIdToColorMatches[matches[0]] = append(routeIdToColorMatches[matches[0]], route)
+		}
+	}
+	for routeId, colorMatches := range routeIdToColorMatches {
+		if len(colorMatches) == 1 {
+			result[colorMatches[0]] = routeId
+		}
+	}
+	return result
+}
+
+// StationToStopId returns the map from source API station to GTFS static stop ID derived from the feed.
+func (s *GtfsStatic) StationToStopId() map[sourceapi.Station]string {
+	return s.stationToStopId
+}
+
+// RouteToRouteId returns the map from source API route to GTFS static route ID derived from the feed.
+func (s *GtfsStatic) RouteToRouteId() map[sourceapi.Route]string {
+	return s.routeToRouteId
+}
+
+// MatchReport returns a human readable description of the source API stations and routes that could
+// not be matched to the GTFS static feed, and of the GTFS static stations and routes that no source API
+// station or route was matched to.
+func (s *GtfsStatic) MatchReport() []string {
+	report := []string{
+		fmt.Sprintf("GTFS static: matched %d of %d stations and %d of %d routes",
+			len(s.stationToStopId), len(sourceStationToName), len(s.routeToRouteId), len(sourceRouteToMetadata)),
+	}
+	matchedStopIds := map[string]bool{}
+	for _, stopId := range s.stationToStopId {
+		matchedStopIds[stopId] = true
+	}
+	matchedRouteIds := map[string]bool{}
+	for _, routeId := range s.routeToRouteId {
+		matchedRouteIds[routeId] = true
+	}
+	var lines []string
+	for station := range sourceStationToName {
+		if _, ok := s.stationToStopId[station]; !ok {
+			lines = append(lines, fmt.Sprintf("GTFS static: no stop found for station %s", station))
+		}
+	}
+	for route := range sourceRouteToMetadata {
+		if _, ok := s.routeToRouteId[route]; !ok {
+			lines = append(lines, fmt.Sprintf("GTFS static: no route found for route %s", route))
+		}
+	}
+	for _, stop := range s.stops {
+		if stop.locationType == "1" && !matchedStopIds[stop.id] {
+			lines = append(lines, fmt.Sprintf("GTFS static: station %s (%s) does not correspond to any source station", stop.id, stop.name))
+		}
+	}
+	for _, route := range s.routes {
+		if !matchedRouteIds[route.id] {
+			lines = append(lines, fmt.Sprintf("GTFS static: route %s (%s %s) does not correspond to any source route", route.id, route.shortName, route.longName))
+		}
+	}
+	for _, direction := range []sourceapi.Direction{sourceapi.Direction_TO_NY, sourceapi.Direction_TO_NJ} {
+		found := false
+		for directionId, d := range s.directionIdToDirection {
+			if d == direction {
+				report = append(report, fmt.Sprintf("GTFS static: direction_id %s is %s", directionId, direction))
+				found = true
+			}
+		}
+		if !found {
+			lines = append(lines, fmt.Sprintf("GTFS static: no direction_id found for direction %s", direction))
+		}
+	}
+	sort.Strings(lines)
+	return append(report, lines...)
+}
+
+// GtfsStaticSourceClient is a source client that wraps another source client and replaces the
+// stop and route IDs it returns with the IDs matched in a GTFS static feed.
+//
+// Stations and routes that could not be matched in the GTFS static feed keep the IDs returned by
+// the wrapped source client.
+type GtfsStaticSourceClient struct {
+	SourceClient
+	gtfsStatic *GtfsStatic
+}
+
+func NewGtfsStaticSourceClient(sourceClient SourceClient, gtfsStatic *GtfsStatic) *GtfsStaticSourceClient {
+	return &GtfsStaticSourceClient{SourceClient: sourceClient, gtfsStatic: gtfsStatic}
+}
+
+func (client *GtfsStaticSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	stationToStopId, err := client.SourceClient.GetStationToStopId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[sourceapi.Station]string{}
+	for station, stopId := range stationToStopId {
+		if staticStopId, ok := client.gtfsStatic.stationToStopId[station]; ok {
+			stopId = staticStopId
+		}
+		result[station] = stopId
+	}
+	return result, nil
+}
+
+func (client *GtfsStaticSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	routeToRouteId, err := client.SourceClient.GetRouteToRouteId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[sourceapi.Route]string{}
+	for route, routeId := range routeToRouteId {
+		if staticRouteId, ok := client.gtfsStatic.routeToRouteId[route]; ok {
+			routeId = staticRouteId
+		}
+		result[route] = routeId
+	}
+	return result, nil
+}
@@ -0,0 +1,284 @@
+package pathgtfsrt
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestLoadGtfsStatic(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "gtfs.zip")
+	if err := os.WriteFile(zipPath, zipGtfsStaticDir(t, "mock_data/gtfs_static"), 0644); err != nil {
+		t.Fatalf("os.WriteFile() err got=%v, want=<nil>", err)
+	}
+
+	gtfsStatic, err := LoadGtfsStatic(zipPath, nil)
+	if err != nil {
+		t.Fatalf("LoadGtfsStatic() err got=%v, want=<nil>", err)
+	}
+
+	if diff := cmp.Diff(sourceStationToGtfsStopId, gtfsStatic.StationToStopId()); diff != "" {
+		t.Errorf("StationToStopId() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(sourceRouteToGtfsRouteId, gtfsStatic.RouteToRouteId()); diff != "" {
+		t.Errorf("RouteToRouteId() mismatch (-want +got):\n%s", diff)
+	}
+	wantReport := []string{
+		"GTFS static: matched 13 of 13 stations and 5 of 6 routes",
+		"GTFS static: direction_id 1 is TO_NY",
+		"GTFS static: direction_id 0 is TO_NJ",
+		"GTFS static: no route found for route NPT_HOB",
+	}
+	if diff := cmp.Diff(wantReport, gtfsStatic.MatchReport()); diff != "" {
+		t.Errorf("MatchReport() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadGtfsStatic_ColorFallback(t *testing.T) {
+	// Without its own route, the color of JSQ_33_HOB only matches the route JSQ_33 is matched to by name.
+	dir := copyGtfsStaticDir(t, "routes.txt", func(data []byte) []byte {
+		return bytes.Replace(data, []byte("1024,151,JSQ-33 via HOB,Journal Square - 33rd Street (via Hoboken),2,FF9900,000000\n"), nil, 1)
+	})
+
+	gtfsStatic, err := parseGtfsStatic(zipGtfsStaticDir(t, dir))
+	if err != nil {
+		t.Fatalf("parseGtfsStatic() err got=%v, want=<nil>", err)
+	}
+
+	wantRouteToRouteId := map[sourceapi.Route]string{}
+	for route, routeId := range sourceRouteToGtfsRouteId {
+		if route != sourceapi.Route_JSQ_33_HOB {
+			wantRouteToRouteId[route] = routeId
+		}
+	}
+	if diff := cmp.Diff(wantRouteToRouteId, gtfsStatic.RouteToRouteId()); diff != "" {
+		t.Errorf("RouteToRouteId() mismatch (-want +got):\n%s", diff)
+	}
+	wantReport := []string{
+		"GTFS static: matched 13 of 13 stations and 4 of 6 routes",
+		"GTFS static: direction_id 1 is TO_NY",
+		"GTFS static: direction_id 0 is TO_NJ",
+		"GTFS static: no route found for route JSQ_33_HOB",
+		"GTFS static: no route found for route NPT_HOB",
+	}
+	if diff := cmp.Diff(wantReport, gtfsStatic.MatchReport()); diff != "" {
+		t.Errorf("MatchReport() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadGtfsStatic_MissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip.Writer.Close() err got=%v, want=<nil>", err)
+	}
+
+	_, err := parseGtfsStatic(buf.Bytes())
+	if err == nil {
+		t.Errorf("parseGtfsStatic() err got=<nil>, want=<non-nil>")
+	}
+}
+
+func TestLoadGtfsStatic_HttpError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := LoadGtfsStatic(server.URL+"/gtfs.zip", server.Client())
+	if err == nil {
+		t.Errorf("LoadGtfsStatic() err got=<nil>, want=<non-nil>")
+	}
+}
+
+func TestLoadGtfsStatic_EmptyArrivalTimes(t *testing.T) {
+	dir := copyGtfsStaticDir(t, "stop_times.txt", func(data []byte) []byte {
+		// The second and third stops of the trip aren't timepoints.
+		data = bytes.Replace(data, []byte("859_WKDY_1_0600,06:03:00,06:03:00,"), []byte("859_WKDY_1_0600,,,"), 1)
+		return bytes.Replace(data, []byte("859_WKDY_1_0600,06:06:00,06:06:00,"), []byte("859_WKDY_1_0600,,,"), 1)
+	})
+
+	gtfsStatic, err := parseGtfsStatic(zipGtfsStaticDir(t, dir))
+	if err != nil {
+		t.Fatalf("parseGtfsStatic() err got=%v, want=<nil>", err)
+	}
+
+	var got []int
+	for _, stopTime := range gtfsStatic.stopTimes["859_WKDY_1_0600"][:4] {
+		if !stopTime.hasArrivalTime {
+			t.Errorf("stop time %+v has no arrival time", stopTime)
+		}
+		got = append(got, stopTime.arrivalTime)
+	}
+	want := []int{6 * 3600, 6*3600 + 3*60, 6*3600 + 6*60, 6*3600 + 9*60}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("arrival times mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadGtfsStatic_Directions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit func([]byte) []byte
+		want map[string]sourceapi.Direction
+	}{
+		{
+			name: "direction_id 1 to New York",
+			edit: func(data []byte) []byte { return data },
+			want: map[string]sourceapi.Direction{"0": sourceapi.Direction_TO_NJ, "1": sourceapi.Direction_TO_NY},
+		},
+		{
+			name: "direction_id 0 to New York",
+			edit: func(data []byte) []byte {
+				data = bytes.ReplaceAll(data, []byte(",0\n"), []byte(",x\n"))
+				data = bytes.ReplaceAll(data, []byte(",1\n"), []byte(",0\n"))
+				return bytes.ReplaceAll(data, []byte(",x\n"), []byte(",1\n"))
+			},
+			want: map[string]sourceapi.Direction{"0": sourceapi.Direction_TO_NY, "1": sourceapi.Direction_TO_NJ},
+		},
+		{
+			name: "same direction_id for both directions",
+			edit: func(data []byte) []byte { return bytes.ReplaceAll(data, []byte(",0\n"), []byte(",1\n")) },
+			want: map[string]sourceapi.Direction{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := copyGtfsStaticDir(t, "trips.txt", tc.edit)
+
+			gtfsStatic, err := parseGtfsStatic(zipGtfsStaticDir(t, dir))
+			if err != nil {
+				t.Fatalf("parseGtfsStatic() err got=%v, want=<nil>", err)
+			}
+
+			if diff := cmp.Diff(tc.want, gtfsStatic.directionIdToDirection); diff != "" {
+				t.Errorf("directionIdToDirection mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInterpolateArrivalTimes(t *testing.T) {
+	stopTimes := []gtfsStopTime{
+		{stopId: "1"},
+		{stopId: "2", arrivalTime: 100, hasArrivalTime: true},
+		{stopId: "3"},
+		{stopId: "4"},
+		{stopId: "5", arrivalTime: 400, hasArrivalTime: true},
+		{stopId: "6"},
+	}
+
+	interpolateArrivalTimes(stopTimes)
+
+	want := []gtfsStopTime{
+		{stopId: "1"},
+		{stopId: "2", arrivalTime: 100, hasArrivalTime: true},
+		{stopId: "3", arrivalTime: 200, hasArrivalTime: true},
+		{stopId: "4", arrivalTime: 300, hasArrivalTime: true},
+		{stopId: "5", arrivalTime: 400, hasArrivalTime: true},
+		{stopId: "6"},
+	}
+	if diff := cmp.Diff(want, stopTimes, cmp.AllowUnexported(gtfsStopTime{})); diff != "" {
+		t.Errorf("interpolateArrivalTimes() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGtfsStaticSourceClient(t *testing.T) {
+	gtfsStatic := loadTestGtfsStatic(t)
+	client := NewGtfsStaticSourceClient(&mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN:             "oldHobokenID",
+			sourceapi.Station_STATION_UNSPECIFIED: "unknownID",
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33:  "oldHob33ID",
+			sourceapi.Route_NPT_HOB: "nptHobID",
+		},
+	}, gtfsStatic)
+	ctx := context.Background()
+
+	stationToStopId, err := client.GetStationToStopId(ctx)
+	if err != nil {
+		t.Fatalf("GetStationToStopId() err got=%v, want=<nil>", err)
+	}
+	routeToRouteId, err := client.GetRouteToRouteId(ctx)
+	if err != nil {
+		t.Fatalf("GetRouteToRouteId() err got=%v, want=<nil>", err)
+	}
+
+	wantStationToStopId := map[sourceapi.Station]string{
+		sourceapi.Station_HOBOKEN:             "26730",
+		sourceapi.Station_STATION_UNSPECIFIED: "unknownID",
+	}
+	if diff := cmp.Diff(wantStationToStopId, stationToStopId); diff != "" {
+		t.Errorf("GetStationToStopId() mismatch (-want +got):\n%s", diff)
+	}
+	wantRouteToRouteId := map[sourceapi.Route]string{
+		sourceapi.Route_HOB_33:  "859",
+		sourceapi.Route_NPT_HOB: "nptHobID",
+	}
+	if diff := cmp.Diff(wantRouteToRouteId, routeToRouteId); diff != "" {
+		t.Errorf("GetRouteToRouteId() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func loadTestGtfsStatic(t *testing.T) *GtfsStatic {
+	gtfsStatic, err := parseGtfsStatic(zipGtfsStaticDir(t, "mock_data/gtfs_static"))
+	if err != nil {
+		t.Fatalf("parseGtfsStatic() err got=%v, want=<nil>", err)
+	}
+	return gtfsStatic
+}
+
+// Copies the test GTFS static feed to a temporary directory, editing one of its files.
+func copyGtfsStaticDir(t *testing.T, fileName string, edit func([]byte) []byte) string {
+	dir := t.TempDir()
+	entries, err := os.ReadDir("mock_data/gtfs_static")
+	if err != nil {
+		t.Fatalf("os.ReadDir() err got=%v, want=<nil>", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("mock_data/gtfs_static", entry.Name()))
+		if err != nil {
+			t.Fatalf("os.ReadFile() err got=%v, want=<nil>", err)
+		}
+		if entry.Name() == fileName {
+			data = edit(data)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), data, 0644); err != nil {
+			t.Fatalf("os.WriteFile() err got=%v, want=<nil>", err)
+		}
+	}
+	return dir
+}
+
+func zipGtfsStaticDir(t *testing.T, dir string) []byte {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("os.ReadDir() err got=%v, want=<nil>", err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("os.ReadFile() err got=%v, want=<nil>", err)
+		}
+		f, err := w.Create(entry.Name())
+		if err != nil {
+			t.Fatalf("zip.Writer.Create() err got=%v, want=<nil>", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatalf("zip file Write() err got=%v, want=<nil>", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip.Writer.Close() err got=%v, want=<nil>", err)
+	}
+	return buf.Bytes()
+}
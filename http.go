@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -21,20 +22,31 @@ const (
 
 // HttpSourceClient is a source client that gets data using the Razza HTTP API.
 type HttpSourceClient struct {
-	httpClient    HttpClient
+	httpClient HttpClient
+	baseUrl    string
 }
 
 func NewHttpSourceClient(httpClient HttpClient) *HttpSourceClient {
-	return &HttpSourceClient{httpClient: httpClient}
+	return NewHttpSourceClientWithBaseUrl(httpClient, apiBaseUrl)
+}
+
+// NewHttpSourceClientWithBaseUrl creates a new HTTP source client for an API at a base URL other than the Razza
+// HTTP API, such as the mock API served by cmd/mockpath. The base URL ends in /v1/.
+func NewHttpSourceClientWithBaseUrl(httpClient HttpClient, baseUrl string) *HttpSourceClient {
+	return &HttpSourceClient{httpClient: httpClient, baseUrl: baseUrl}
 }
 
 func (client *HttpSourceClient) GetTrainsAtStation(_ context.Context, station sourceapi.Station) ([]Train, error) {
 	type jsonUpcomingTrain struct {
 		ProjectedArrival  string
 		LastUpdated       string
-		RouteAsString     string `json:"route"`
-		DirectionAsString string `json:"direction"`
-		LineName          string `json:"lineName"`
+		RouteAsString     string   `json:"route"`
+		DirectionAsString string   `json:"direction"`
+		LineName          string   `json:"lineName"`
+		Headsign          string   `json:"headsign"`
+		RouteDisplayName  string   `json:"routeDisplayName"`
+		LineColors        []string `json:"lineColors"`
+		Status            string   `json:"status"`
 	}
 	type jsonGetUpcomingTrainsResponse struct {
 		Trains []jsonUpcomingTrain `json:"upcomingTrains"`
@@ -54,8 +66,12 @@ func (client *HttpSourceClient) GetTrainsAtStation(_ context.Context, station so
 		upcomingTrain := sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
 			Route:            client.convertRouteAsStringToRoute(rawUpcomingTrain.RouteAsString),
 			LineName:         rawUpcomingTrain.LineName,
+			Headsign:         rawUpcomingTrain.Headsign,
+			RouteDisplayName: rawUpcomingTrain.RouteDisplayName,
 			Direction:        client.convertDirectionAsStringToDirection(rawUpcomingTrain.DirectionAsString),
+			LineColors:       rawUpcomingTrain.LineColors,
 			ProjectedArrival: client.convertApiTimeStringToTimestamp(rawUpcomingTrain.ProjectedArrival),
+			Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_Status(sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_Status_value[rawUpcomingTrain.Status]),
 			LastUpdated:      client.convertApiTimeStringToTimestamp(rawUpcomingTrain.LastUpdated),
 		}
 		applyRouteQaToTrain(&upcomingTrain)
@@ -134,7 +150,7 @@ func (client *HttpSourceClient) convertApiTimeStringToTimestamp(timeString strin
 
 // Get the raw bytes from an endpoint in the API.
 func (client HttpSourceClient) getContent(endpoint string) (bytes []byte, err error) {
-	resp, err := client.httpClient.Get(apiBaseUrl + endpoint)
+	resp, err := client.httpClient.Get(client.baseUrl + endpoint)
 	if err != nil {
 		return
 	}
@@ -144,5 +160,8 @@ func (client HttpSourceClient) getContent(endpoint string) (bytes []byte, err er
 			err = closingErr
 		}
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("source API returned status %s", resp.Status)
+	}
 	return io.ReadAll(resp.Body)
 }
@@ -34,6 +34,10 @@ func TestSourceHttpGetTrainsAtStation(t *testing.T) {
 					Route:            sourceapi.Route_JSQ_33_HOB,
 					Direction:        sourceapi.Direction_TO_NY,
 					LineName:         "33rd Street via Hoboken",
+					Headsign:         "33rd Street via Hoboken",
+					RouteDisplayName: "Journal Square - 33rd Street (via Hoboken)",
+					LineColors:       []string{"#4D92FB", "#FF9900"},
+					Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
 					ProjectedArrival: mkTimestampFromRfc3339("2023-12-23T05:36:15Z"),
 					LastUpdated:      mkTimestampFromRfc3339("2023-12-23T05:35:44Z"),
 				},
@@ -41,6 +45,10 @@ func TestSourceHttpGetTrainsAtStation(t *testing.T) {
 					Route:            sourceapi.Route_JSQ_33_HOB,
 					Direction:        sourceapi.Direction_TO_NY,
 					LineName:         "33rd Street via Hoboken",
+					Headsign:         "33rd Street via Hoboken",
+					RouteDisplayName: "Journal Square - 33rd Street (via Hoboken)",
+					LineColors:       []string{"#4D92FB", "#FF9900"},
+					Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
 					ProjectedArrival: mkTimestampFromRfc3339("2023-12-23T06:01:30Z"),
 					LastUpdated:      mkTimestampFromRfc3339("2023-12-23T05:35:44Z"),
 				},
@@ -48,6 +56,10 @@ func TestSourceHttpGetTrainsAtStation(t *testing.T) {
 					Route:            sourceapi.Route_JSQ_33_HOB,
 					Direction:        sourceapi.Direction_TO_NJ,
 					LineName:         "Journal Square via Hoboken",
+					Headsign:         "Journal Square via Hoboken",
+					RouteDisplayName: "33rd Street (via Hoboken) - Journal Square",
+					LineColors:       []string{"#4D92FB", "#FF9900"},
+					Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
 					ProjectedArrival: mkTimestampFromRfc3339("2023-12-23T05:36:15Z"),
 					LastUpdated:      mkTimestampFromRfc3339("2023-12-23T05:35:44Z"),
 				},
@@ -55,6 +67,10 @@ func TestSourceHttpGetTrainsAtStation(t *testing.T) {
 					Route:            sourceapi.Route_JSQ_33_HOB,
 					Direction:        sourceapi.Direction_TO_NJ,
 					LineName:         "Journal Square via Hoboken",
+					Headsign:         "Journal Square via Hoboken",
+					RouteDisplayName: "33rd Street (via Hoboken) - Journal Square",
+					LineColors:       []string{"#4D92FB", "#FF9900"},
+					Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
 					ProjectedArrival: mkTimestampFromRfc3339("2023-12-23T06:02:44Z"),
 					LastUpdated:      mkTimestampFromRfc3339("2023-12-23T05:35:44Z"),
 				},
@@ -70,6 +86,10 @@ func TestSourceHttpGetTrainsAtStation(t *testing.T) {
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
 					LineName:         "33rd Street",
+					Headsign:         "33rd Street",
+					RouteDisplayName: "Journal Square - 33rd Street (via Hoboken)",
+					LineColors:       []string{"#FF9900"},
+					Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
 					ProjectedArrival: mkTimestampFromRfc3339("2023-12-27T00:09:21Z"),
 					LastUpdated:      mkTimestampFromRfc3339("2023-12-27T00:01:24Z"),
 				},
@@ -1,7 +1,26 @@
 package pathgtfsrt
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 type HttpClient interface {
 	Get(url string) (resp *http.Response, err error)
 }
+
+// Makes a GET request that is cancelled with the context if the HTTP client supports it, like *http.Client does.
+// Otherwise the context is ignored.
+func getWithContext(ctx context.Context, httpClient HttpClient, url string) (*http.Response, error) {
+	doer, ok := httpClient.(interface {
+		Do(req *http.Request) (*http.Response, error)
+	})
+	if !ok {
+		return httpClient.Get(url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doer.Do(req)
+}
@@ -0,0 +1,185 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// Trains from different sources with the same route and direction whose predicted arrivals at a station are
+// within this window of each other are considered to be the same train.
+const mergeArrivalWindow = 2 * time.Minute
+
+// DisagreementCallback is the type of callback that the merging source client runs for each train reported by
+// more than one source. The disagreement is the difference between the predicted arrival times.
+type DisagreementCallback func(station sourceapi.Station, disagreement time.Duration)
+
+// MergingSourceClient is a source client that gets upcoming trains from several sources concurrently and
+// reconciles them.
+//
+// Trains reported by more than one source are matched using their route, direction and predicted arrival time.
+// For each matched train the prediction with the most recent LastUpdated time is used, ties being broken by the
+// order of the sources, and fields missing from that prediction, like the headsign, are taken from the others.
+// Trains reported by only one source are kept.
+//
+// Static data is taken from the first source that returns it.
+type MergingSourceClient struct {
+	sources              []NamedSourceClient
+	disagreementCallback DisagreementCallback
+}
+
+// NewMergingSourceClient creates a new merging source client. The disagreement callback may be nil.
+func NewMergingSourceClient(disagreementCallback DisagreementCallback, sources ...NamedSourceClient) *MergingSourceClient {
+	return &MergingSourceClient{sources: sources, disagreementCallback: disagreementCallback}
+}
+
+func (c *MergingSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	var errs []error
+	for _, source := range c.sources {
+		stationToStopId, err := source.Client.GetStationToStopId(ctx)
+		if err == nil {
+			return stationToStopId, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+	}
+	return nil, fmt.Errorf("all sources failed: %v", errs)
+}
+
+func (c *MergingSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	var errs []error
+	for _, source := range c.sources {
+		routeToRouteId, err := source.Client.GetRouteToRouteId(ctx)
+		if err == nil {
+			return routeToRouteId, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+	}
+	return nil, fmt.Errorf("all sources failed: %v", errs)
+}
+
+// GetTrainsAtStation returns the reconciled trains from all of the sources that respond successfully.
+// An error is returned only if every source fails.
+func (c *MergingSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	type result struct {
+		trains []Train
+		err    error
+	}
+	results := make([]result, len(c.sources))
+	done := make(chan struct{})
+	for i, source := range c.sources {
+		i, source := i, source
+		go func() {
+			results[i].trains, results[i].err = source.Client.GetTrainsAtStation(ctx, station)
+			done <- struct{}{}
+		}()
+	}
+	for range c.sources {
+		<-done
+	}
+	var sourceTrains [][]Train
+	var errs []error
+	for i, result := range results {
+		if result.err != nil {
+			fmt.Printf("Source %s failed to get trains at station %s: %s\n", c.sources[i].Name, station, result.err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.sources[i].Name, result.err))
+			continue
+		}
+		sourceTrains = append(sourceTrains, result.trains)
+	}
+	if len(sourceTrains) == 0 {
+		return nil, fmt.Errorf("all sources failed: %v", errs)
+	}
+	return mergeTrains(sourceTrains, func(disagreement time.Duration) {
+		if c.disagreementCallback != nil {
+			c.disagreementCallback(station, disagreement)
+		}
+	}), nil
+}
+
+// Merges the trains at a station reported by several sources, in order of preference.
+//
+// Each train is matched with the unmatched train from the previous sources that has the same route and direction
+// and the closest arrival time within the merge window. The input trains are not modified.
+func mergeTrains(sourceTrains [][]Train, onMatch func(disagreement time.Duration)) []Train {
+	type mergedTrain struct {
+		train Train
+		// Whether a train from the current source has been matched with this train.
+		matched bool
+	}
+	var merged []*mergedTrain
+	for _, trains := range sourceTrains {
+		for _, m := range merged {
+			m.matched = false
+		}
+		var added []*mergedTrain
+		for _, train := range trains {
+			if train.ProjectedArrival == nil {
+				added = append(added, &mergedTrain{train: train})
+				continue
+			}
+			var best *mergedTrain
+			var bestDiff time.Duration
+			for _, m := range merged {
+				if m.matched || m.train.Route != train.Route || m.train.Direction != train.Direction || m.train.ProjectedArrival == nil {
+					continue
+				}
+				diff := train.ProjectedArrival.AsTime().Sub(m.train.ProjectedArrival.AsTime())
+				if diff < 0 {
+					diff = -diff
+				}
+				if diff > mergeArrivalWindow {
+					continue
+				}
+				if best == nil || diff < bestDiff {
+					best, bestDiff = m, diff
+				}
+			}
+			if best == nil {
+				added = append(added, &mergedTrain{train: train})
+				continue
+			}
+			onMatch(bestDiff)
+			best.matched = true
+			best.train = reconcileTrains(best.train, train)
+		}
+		merged = append(merged, added...)
+	}
+	var result []Train
+	for _, m := range merged {
+		result = append(result, m.train)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ProjectedArrival.AsTime().Before(result[j].ProjectedArrival.AsTime())
+	})
+	return result
+}
+
+// Returns the freshest of two predictions for the same train, with missing fields filled in from the other.
+// If both predictions were updated at the same time, the first is used.
+func reconcileTrains(a, b Train) Train {
+	freshest, other := a, b
+	if b.LastUpdated.AsTime().After(a.LastUpdated.AsTime()) {
+		freshest, other = b, a
+	}
+	result := Train(proto.Clone((*sourceapi.GetUpcomingTrainsResponse_UpcomingTrain)(freshest)).(*sourceapi.GetUpcomingTrainsResponse_UpcomingTrain))
+	if result.Headsign == "" {
+		result.Headsign = other.Headsign
+	}
+	if result.LineName == "" {
+		result.LineName = other.LineName
+	}
+	if result.RouteDisplayName == "" {
+		result.RouteDisplayName = other.RouteDisplayName
+	}
+	if len(result.LineColors) == 0 {
+		result.LineColors = append([]string(nil), other.LineColors...)
+	}
+	if result.Status == sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_STATUS_UNSPECIFIED {
+		result.Status = other.Status
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMergeTrains(t *testing.T) {
+	train := func(route sourceapi.Route, arrival time.Duration, lastUpdated time.Duration, headsign string) Train {
+		return Train(&sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
+			Route:            route,
+			Direction:        sourceapi.Direction_TO_NY,
+			Headsign:         headsign,
+			ProjectedArrival: timestamppb.New(makeTime(0).Add(arrival)),
+			LastUpdated:      timestamppb.New(makeTime(0).Add(lastUpdated)),
+		})
+	}
+	for _, tc := range []struct {
+		name              string
+		sourceTrains      [][]Train
+		want              []Train
+		wantDisagreements []time.Duration
+	}{
+		{
+			name: "single source",
+			sourceTrains: [][]Train{
+				{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "33rd Street")},
+			},
+			want: []Train{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "33rd Street")},
+		},
+		{
+			name: "freshest prediction wins",
+			sourceTrains: [][]Train{
+				{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "33rd Street")},
+				{train(sourceapi.Route_HOB_33, 5*time.Minute+30*time.Second, 10*time.Second, "")},
+			},
+			want:              []Train{train(sourceapi.Route_HOB_33, 5*time.Minute+30*time.Second, 10*time.Second, "33rd Street")},
+			wantDisagreements: []time.Duration{30 * time.Second},
+		},
+		{
+			name: "tie uses first source",
+			sourceTrains: [][]Train{
+				{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "33rd Street")},
+				{train(sourceapi.Route_HOB_33, 4*time.Minute, 0, "33rd St")},
+			},
+			want:              []Train{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "33rd Street")},
+			wantDisagreements: []time.Duration{time.Minute},
+		},
+		{
+			name: "different routes are not matched",
+			sourceTrains: [][]Train{
+				{train(sourceapi.Route_HOB_33, 5*time.Minute, 0, "")},
+				{train(sourceapi.Route_HOB_WTC, 5*time.Minute, 0, "")},
+			},
+			want: []Train{
+				train(sourceapi.Route_HOB_33, 5*time.Minute, 0, ""),
+				train(sourceapi.Route_HOB_WTC, 5*time.Minute, 0, ""),
+			},
+		},
+		{
+			name: "closest train is matched",
+			sourceTrains: [][]Train{
+				{
+					train(sourceapi.Route_HOB_33, 2*time.Minute, 0, "a"),
+					train(sourceapi.Route_HOB_33, 3*time.Minute, 0, "b"),
+				},
+				{
+					train(sourceapi.Route_HOB_33, 3*time.Minute+20*time.Second, time.Second, ""),
+					train(sourceapi.Route_HOB_33, 10*time.Minute, 0, "c"),
+				},
+			},
+			want: []Train{
+				train(sourceapi.Route_HOB_33, 2*time.Minute, 0, "a"),
+				train(sourceapi.Route_HOB_33, 3*time.Minute+20*time.Second, time.Second, "b"),
+				train(sourceapi.Route_HOB_33, 10*time.Minute, 0, "c"),
+			},
+			wantDisagreements: []time.Duration{20 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotDisagreements []time.Duration
+
+			got := mergeTrains(tc.sourceTrains, func(disagreement time.Duration) {
+				gotDisagreements = append(gotDisagreements, disagreement)
+			})
+
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("mergeTrains() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDisagreements, gotDisagreements); diff != "" {
+				t.Errorf("disagreements mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergingSourceClient(t *testing.T) {
+	working := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+		},
+	}
+	// Has no trains, so requests for any station fail.
+	broken := &mockSourceClient{}
+	client := NewMergingSourceClient(nil,
+		NamedSourceClient{Name: "broken", Client: broken},
+		NamedSourceClient{Name: "working", Client: working},
+	)
+
+	trains, err := client.GetTrainsAtStation(context.Background(), sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(working.stationToTrains[sourceapi.Station_HOBOKEN], trains, protocmp.Transform()); diff != "" {
+		t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := client.GetTrainsAtStation(context.Background(), sourceapi.Station_NEWARK); err == nil {
+		t.Errorf("GetTrainsAtStation() err got=<nil>, want error when all sources fail")
+	}
+}
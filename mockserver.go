@@ -0,0 +1,286 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// The path of the PANYNJ API endpoint on the mock server.
+const MockPaNyNjApiPath = "/bin/portauthority/ridepath.json"
+
+// MockServerFaults are the faults injected into responses by a MockServer. Each rate is the fraction of responses,
+// or trains, that the fault is applied to.
+type MockServerFaults struct {
+	// Added to every response.
+	Latency time.Duration
+	// Failed requests get a 500 over HTTP and Unavailable over gRPC.
+	ErrorRate float64
+	// Malformed HTTP responses have their JSON truncated.
+	MalformedRate float64
+	// Trains with missing fields have one of their fields cleared.
+	MissingFieldsRate float64
+}
+
+// MockServer serves stand-ins for the source APIs using data from a source client, typically a
+// TimetableSourceClient, with injected faults. It is used for integration and load tests.
+//
+// Over HTTP it serves the Razza HTTP API under /v1/ and the PANYNJ API at MockPaNyNjApiPath. Over gRPC it serves the
+// Razza Stations and Routes services.
+type MockServer struct {
+	sourceClient SourceClient
+	sourceServer *SourceServer
+	clock        clock.Clock
+	faults       MockServerFaults
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewMockServer creates a new mock server. The seed determines which responses faults are injected into.
+func NewMockServer(sourceClient SourceClient, clock clock.Clock, faults MockServerFaults, seed int64) *MockServer {
+	s := &MockServer{
+		clock:  clock,
+		faults: faults,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+	s.sourceClient = &missingFieldsSourceClient{SourceClient: sourceClient, server: s}
+	s.sourceServer = NewSourceServer(s.sourceClient)
+	return s
+}
+
+// Returns true with the given probability.
+func (s *MockServer) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rand.Float64() < rate
+}
+
+func (s *MockServer) injectLatency() {
+	if s.faults.Latency > 0 {
+		s.clock.Sleep(s.faults.Latency)
+	}
+}
+
+// Register registers the Stations and Routes services on the gRPC server. Faults are only injected into gRPC
+// requests if the server uses the interceptor returned by UnaryInterceptor.
+func (s *MockServer) Register(server *grpc.Server) {
+	s.sourceServer.Register(server)
+}
+
+// UnaryInterceptor returns a gRPC interceptor that injects latency and errors into requests.
+func (s *MockServer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.injectLatency()
+		if s.roll(s.faults.ErrorRate) {
+			return nil, status.Error(codes.Unavailable, "injected fault")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// HttpHandler returns the handler for the HTTP APIs.
+func (s *MockServer) HttpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.injectLatency()
+		if s.roll(s.faults.ErrorRate) {
+			writeMockError(w, status.Error(codes.Unavailable, "injected fault"))
+			return
+		}
+		var b []byte
+		var err error
+		if r.URL.Path == MockPaNyNjApiPath {
+			b, err = s.ridePath(r.Context())
+		} else {
+			b, err = s.razza(r.Context(), r.URL.Path)
+		}
+		if err != nil {
+			writeMockError(w, err)
+			return
+		}
+		if s.roll(s.faults.MalformedRate) {
+			b = b[:len(b)/2]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeBytes(w, b)
+	})
+}
+
+// Returns the response of the Razza HTTP API. Like the real API, trailing slashes are optional.
+func (s *MockServer) razza(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v1" {
+		return nil, status.Errorf(codes.NotFound, "unknown path %q", path)
+	}
+	var message proto.Message
+	var err error
+	switch {
+	case len(parts) == 2 && parts[1] == "stations":
+		message, err = s.sourceServer.ListStations(ctx, &sourceapi.ListStationsRequest{})
+	case len(parts) == 3 && parts[1] == "stations":
+		message, err = s.sourceServer.GetStation(ctx, &sourceapi.GetStationRequest{Station: parseMockStation(parts[2])})
+	case len(parts) == 4 && parts[1] == "stations" && parts[3] == "realtime":
+		message, err = s.sourceServer.GetUpcomingTrains(ctx, &sourceapi.GetUpcomingTrainsRequest{Station: parseMockStation(parts[2])})
+	case len(parts) == 2 && parts[1] == "routes":
+		message, err = s.sourceServer.ListRoutes(ctx, &sourceapi.ListRoutesRequest{})
+	case len(parts) == 3 && parts[1] == "routes":
+		route := sourceapi.Route(sourceapi.Route_value[strings.ToUpper(parts[2])])
+		message, err = s.sourceServer.GetRoute(ctx, &sourceapi.GetRouteRequest{Route: route})
+	default:
+		return nil, status.Errorf(codes.NotFound, "unknown path %q", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(message)
+}
+
+func parseMockStation(s string) sourceapi.Station {
+	return sourceapi.Station(sourceapi.Station_value[strings.ToUpper(s)])
+}
+
+// Returns the response of the PANYNJ API, which contains the trains at every station.
+func (s *MockServer) ridePath(ctx context.Context) ([]byte, error) {
+	stationToStopId, err := s.sourceClient.GetStationToStopId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get stations: %s", err)
+	}
+	var stations []sourceapi.Station
+	for station := range stationToStopId {
+		stations = append(stations, station)
+	}
+	sort.Slice(stations, func(i, j int) bool { return stations[i] < stations[j] })
+	response := RidePathResponse{Results: []Result{}}
+	for _, station := range stations {
+		trains, err := s.sourceClient.GetTrainsAtStation(ctx, station)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to get upcoming trains: %s", err)
+		}
+		result := Result{ConsideredStation: sourceStationToPanynjStation(station)}
+		for _, direction := range []sourceapi.Direction{sourceapi.Direction_TO_NY, sourceapi.Direction_TO_NJ} {
+			destination := Destination{Label: directionToPanynjLabel(direction)}
+			for _, train := range trains {
+				if train.Direction != direction {
+					continue
+				}
+				destination.Messages = append(destination.Messages, buildPanynjMessage(train))
+			}
+			if len(destination.Messages) > 0 {
+				result.Destinations = append(result.Destinations, destination)
+			}
+		}
+		response.Results = append(response.Results, result)
+	}
+	return json.Marshal(response)
+}
+
+func buildPanynjMessage(train Train) Message {
+	var message Message
+	if stations := stationsInDirection(train.Route, train.Direction); len(stations) > 0 {
+		message.Target = sourceStationToPanynjStation(stations[len(stations)-1])
+	}
+	if train.Route != sourceapi.Route_ROUTE_UNSPECIFIED {
+		message.LineColor = routeToPanynjLineColor(train.Route)
+	}
+	message.HeadSign = train.Headsign
+	if train.LastUpdated != nil {
+		loc, err := time.LoadLocation(pathTimezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		message.LastUpdated = train.LastUpdated.AsTime().In(loc).Format("2006-01-02T15:04:05.999999-07:00")
+	}
+	if train.LastUpdated != nil && train.ProjectedArrival != nil {
+		secondsToArrival := train.ProjectedArrival.Seconds - train.LastUpdated.Seconds
+		message.SecondsToArrival = strconv.FormatInt(secondsToArrival, 10)
+		message.ArrivalTimeMessage = fmt.Sprintf("%d min", int(math.Round(float64(secondsToArrival)/60)))
+	}
+	return message
+}
+
+func sourceStationToPanynjStation(station sourceapi.Station) string {
+	for panynjStation, s := range panynjStationToSourceStation {
+		if s == station {
+			return panynjStation
+		}
+	}
+	return ""
+}
+
+func directionToPanynjLabel(direction sourceapi.Direction) string {
+	if direction == sourceapi.Direction_TO_NY {
+		return "ToNY"
+	}
+	return "ToNJ"
+}
+
+// Writes an error in the format of the Razza HTTP API.
+func writeMockError(w http.ResponseWriter, err error) {
+	s := status.Convert(err)
+	code := http.StatusInternalServerError
+	if s.Code() == codes.NotFound {
+		code = http.StatusNotFound
+	}
+	b, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{Code: int(s.Code()), Message: s.Message()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	writeBytes(w, b)
+}
+
+// A source client that clears a field of some of the trains returned by another source client.
+type missingFieldsSourceClient struct {
+	SourceClient
+	server *MockServer
+}
+
+func (c *missingFieldsSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	trains, err := c.SourceClient.GetTrainsAtStation(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	var result []Train
+	for _, train := range trains {
+		if c.server.roll(c.server.faults.MissingFieldsRate) {
+			train = proto.Clone((*sourceapi.GetUpcomingTrainsResponse_UpcomingTrain)(train)).(*sourceapi.GetUpcomingTrainsResponse_UpcomingTrain)
+			c.server.mutex.Lock()
+			field := c.server.rand.Intn(5)
+			c.server.mutex.Unlock()
+			switch field {
+			case 0:
+				train.ProjectedArrival = nil
+			case 1:
+				train.LastUpdated = nil
+			case 2:
+				train.Route = sourceapi.Route_ROUTE_UNSPECIFIED
+			case 3:
+				train.Direction = sourceapi.Direction_DIRECTION_UNSPECIFIED
+			case 4:
+				train.Headsign = ""
+			}
+		}
+		result = append(result, train)
+	}
+	return result, nil
+}
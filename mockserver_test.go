@@ -0,0 +1,95 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestMockServer(t *testing.T) {
+	train := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)
+	train.Headsign = "33rd Street"
+	train.LineColors = []string{"#4D92FB"}
+	source := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+		routeToRouteID:  map[sourceapi.Route]string{sourceapi.Route_HOB_33: routeID1},
+		stationToTrains: map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {train}},
+	}
+	for _, tc := range []struct {
+		name    string
+		faults  MockServerFaults
+		wantErr bool
+	}{
+		{
+			name: "no faults",
+		},
+		{
+			name:    "errors",
+			faults:  MockServerFaults{ErrorRate: 1},
+			wantErr: true,
+		},
+		{
+			name:    "malformed JSON",
+			faults:  MockServerFaults{MalformedRate: 1},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := clock.NewMock()
+			server := httptest.NewServer(NewMockServer(source, c, tc.faults, 1).HttpHandler())
+			defer server.Close()
+			ctx := context.Background()
+
+			for _, client := range []struct {
+				name   string
+				client SourceClient
+			}{
+				{"http", NewHttpSourceClientWithBaseUrl(server.Client(), server.URL+"/v1/")},
+				{"panynj", NewPaNyNjSourceClientWithUrl(server.Client(), c, server.URL+MockPaNyNjApiPath)},
+			} {
+				got, err := client.client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+
+				if gotErr := err != nil; gotErr != tc.wantErr {
+					t.Fatalf("%s GetTrainsAtStation() err got=%v, wantErr=%t", client.name, err, tc.wantErr)
+				}
+				if tc.wantErr {
+					continue
+				}
+				if diff := cmp.Diff([]Train{train}, got, protocmp.Transform()); diff != "" {
+					t.Errorf("%s GetTrainsAtStation() mismatch (-want +got):\n%s", client.name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestMockServer_RazzaStaticData(t *testing.T) {
+	source := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+		routeToRouteID:  map[sourceapi.Route]string{sourceapi.Route_HOB_33: routeID1},
+	}
+	server := httptest.NewServer(NewMockServer(source, clock.NewMock(), MockServerFaults{}, 1).HttpHandler())
+	defer server.Close()
+	client := NewHttpSourceClientWithBaseUrl(server.Client(), server.URL+"/v1/")
+	ctx := context.Background()
+
+	stationToStopId, err := client.GetStationToStopId(ctx)
+	if err != nil {
+		t.Fatalf("GetStationToStopId() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(source.stationToStopID, stationToStopId); diff != "" {
+		t.Errorf("GetStationToStopId() mismatch (-want +got):\n%s", diff)
+	}
+	routeToRouteId, err := client.GetRouteToRouteId(ctx)
+	if err != nil {
+		t.Fatalf("GetRouteToRouteId() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(source.routeToRouteID, routeToRouteId); diff != "" {
+		t.Errorf("GetRouteToRouteId() mismatch (-want +got):\n%s", diff)
+	}
+}
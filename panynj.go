@@ -3,7 +3,9 @@ package pathgtfsrt
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +31,7 @@ type cachedContent struct {
 // It is what is used to power the official realtime schedules on the PATH website: https://www.panynj.gov/path/en/index.html
 type PaNyNjClient struct {
 	httpClient    HttpClient
+	url           string
 	clock         clock.Clock
 	cachedContent *cachedContent
 	mu            sync.RWMutex
@@ -91,7 +94,13 @@ type Message struct {
 }
 
 func NewPaNyNjSourceClient(httpClient HttpClient, clock clock.Clock) *PaNyNjClient {
-	return &PaNyNjClient{httpClient: httpClient, clock: clock}
+	return NewPaNyNjSourceClientWithUrl(httpClient, clock, paNyNjApiUrl)
+}
+
+// NewPaNyNjSourceClientWithUrl creates a new PANYNJ source client for an API at a URL other than the PANYNJ
+// website, such as the mock API served by cmd/mockpath.
+func NewPaNyNjSourceClientWithUrl(httpClient HttpClient, clock clock.Clock, url string) *PaNyNjClient {
+	return &PaNyNjClient{httpClient: httpClient, url: url, clock: clock}
 }
 
 func (client *PaNyNjClient) GetTrainsAtStation(_ context.Context, station sourceapi.Station) ([]Train, error) {
@@ -117,8 +126,10 @@ func (client *PaNyNjClient) GetTrainsAtStation(_ context.Context, station source
 					return nil, err
 				}
 				upcomingTrain := sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
+					Headsign:         message.HeadSign,
 					Route:            client.convertLineColorToRoute(message.LineColor),
 					Direction:        client.convertDirectionAsStringToDirection(destination.Label),
+					LineColors:       convertLineColorToLineColors(message.LineColor),
 					ProjectedArrival: client.convertApiSecondsToArrivalAsStringToTimestamp(lastUpdated, message.SecondsToArrival),
 					LastUpdated:      lastUpdated,
 				}
@@ -199,12 +210,18 @@ func (client *PaNyNjClient) getContent() (bytes []byte, err error) {
 		return cachedData, err
 	}
 
-	url := attachTimestampToUrl(paNyNjApiUrl, client.clock)
+	url := attachTimestampToUrl(client.url, client.clock)
 	resp, err := client.httpClient.Get(url)
 	if err != nil {
 		client.cachedContent = &cachedContent{timestamp: client.clock.Now(), data: nil, error: err}
 		return nil, err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("PANYNJ API returned status %s", resp.Status)
+		client.cachedContent = &cachedContent{timestamp: client.clock.Now(), data: nil, error: err}
+		return nil, err
+	}
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		client.cachedContent = &cachedContent{timestamp: client.clock.Now(), data: nil, error: err}
@@ -215,6 +232,18 @@ func (client *PaNyNjClient) getContent() (bytes []byte, err error) {
 	return data, nil
 }
 
+// Only the trains requests are sent to the PANYNJ API, and the trains of all stations are served from a single
+// cached request.
+func (client *PaNyNjClient) upstreamEndpoint(endpoint string) (string, bool) {
+	if !strings.HasPrefix(endpoint, trainsEndpointPrefix) {
+		return "", false
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	_, _, cached := client.getCachedContent()
+	return "trains", !cached
+}
+
 func (client *PaNyNjClient) getCachedContent() (cachedContent []byte, err error, ok bool) {
 	if client.cachedContent != nil && client.clock.Now().Sub(client.cachedContent.timestamp) < cacheValidityTime {
 		return client.cachedContent.data, client.cachedContent.error, true
@@ -225,3 +254,14 @@ func (client *PaNyNjClient) getCachedContent() (cachedContent []byte, err error,
 func attachTimestampToUrl(url string, clock clock.Clock) string {
 	return url + "?timeStamp=" + strconv.FormatInt(clock.Now().Unix()*1000, 10)
 }
+
+// Converts a comma separated list of hex colors, as used by the PANYNJ API, to the HTML colors used by the source API.
+func convertLineColorToLineColors(lineColor string) []string {
+	var lineColors []string
+	for _, color := range strings.Split(lineColor, ",") {
+		if color = strings.TrimSpace(color); color != "" {
+			lineColors = append(lineColors, "#"+color)
+		}
+	}
+	return lineColors
+}
@@ -31,12 +31,16 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950359),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950959),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
@@ -48,24 +52,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950304),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951175),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950461),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:57.869032-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951061),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:57.869032-05:00"),
 				},
@@ -77,36 +89,48 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950515),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:47.905034-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950941),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:47.905034-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950479),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950486),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951121),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951199),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
@@ -118,24 +142,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950215),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950558),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:52.813168-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950296),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950761),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
@@ -147,24 +179,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950318),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950701),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950131),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950761),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
@@ -176,24 +216,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950401),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950486),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950341),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950476),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
@@ -205,24 +253,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950119),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950539),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951019),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "World Trade Center",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702951259),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
@@ -234,24 +290,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950179),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:12.868217-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950239),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:12.868217-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_NWK_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Newark",
+					LineColors:       []string{"#D93A30"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950839),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:12.868217-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_WTC,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#65C100"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950899),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:12.868217-05:00"),
 				},
@@ -263,24 +327,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950472),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950557),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950638),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950723),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:42.854056-05:00"),
 				},
@@ -292,24 +364,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950391),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950476),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:32.933609-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950761),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950846),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
@@ -325,24 +405,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950208),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950293),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:27.941258-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950187),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:47.905034-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NY,
+					Headsign:         "33rd Street",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950272),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:41:47.905034-05:00"),
 				},
@@ -354,24 +442,32 @@ func TestGetTrainsAtStation(t *testing.T) {
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950127),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950127),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_HOB_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Hoboken",
+					LineColors:       []string{"#4D92FB"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950719),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
 				{
 					Route:            sourceapi.Route_JSQ_33,
 					Direction:        sourceapi.Direction_TO_NJ,
+					Headsign:         "Journal Square",
+					LineColors:       []string{"#FF9900"},
 					ProjectedArrival: mkTimestampFromUnixSeconds(1702950839),
 					LastUpdated:      mkTimestampFromIso8601("2023-12-18T20:42:07.827997-05:00"),
 				},
@@ -508,30 +604,40 @@ func GetFourteenthStreetTrains(c clock.Clock, offset int64) []Train {
 		{
 			Route:            sourceapi.Route_HOB_33,
 			Direction:        sourceapi.Direction_TO_NJ,
+			Headsign:         "Hoboken",
+			LineColors:       []string{"#4D92FB"},
 			ProjectedArrival: mkTimestampFromUnixSeconds(1702950297 + offset),
 			LastUpdated:      mkTimestampFromIso8601WithOffset("2023-12-18T20:41:57.869032-05:00", offset),
 		},
 		{
 			Route:            sourceapi.Route_JSQ_33,
 			Direction:        sourceapi.Direction_TO_NJ,
+			Headsign:         "Journal Square",
+			LineColors:       []string{"#FF9900"},
 			ProjectedArrival: mkTimestampFromUnixSeconds(1702950382 + offset),
 			LastUpdated:      mkTimestampFromIso8601WithOffset("2023-12-18T20:41:57.869032-05:00", offset),
 		},
 		{
 			Route:            sourceapi.Route_JSQ_33_HOB,
 			Direction:        sourceapi.Direction_TO_NJ,
+			Headsign:         "Journal Square via Hoboken",
+			LineColors:       []string{"#4D92FB", "#FF9900"},
 			ProjectedArrival: mkTimestampFromUnixSeconds(1702952629 + offset),
 			LastUpdated:      mkTimestampFromIso8601WithOffset("2023-12-18T20:41:57.869032-05:00", offset),
 		},
 		{
 			Route:            sourceapi.Route_HOB_33,
 			Direction:        sourceapi.Direction_TO_NY,
+			Headsign:         "33rd Street",
+			LineColors:       []string{"#4D92FB"},
 			ProjectedArrival: mkTimestampFromUnixSeconds(1702950134 + offset),
 			LastUpdated:      mkTimestampFromIso8601WithOffset("2023-12-18T20:41:52.813168-05:00", offset),
 		},
 		{
 			Route:            sourceapi.Route_HOB_33,
 			Direction:        sourceapi.Direction_TO_NY,
+			Headsign:         "33rd Street",
+			LineColors:       []string{"#4D92FB"},
 			ProjectedArrival: mkTimestampFromUnixSeconds(1702950821 + offset),
 			LastUpdated:      mkTimestampFromIso8601WithOffset("2023-12-18T20:41:52.813168-05:00", offset),
 		},
@@ -3,8 +3,6 @@ package pathgtfsrt
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
@@ -40,8 +38,101 @@ type SourceClient interface {
 // Feed also satisfies the http.Handler interface, and simply responds to all requests with the most recent
 // GTFS realtime data.
 type Feed struct {
-	gtfs  []byte
-	mutex sync.RWMutex
+	data        feedData
+	mutex       sync.RWMutex
+	broadcaster *broadcaster
+	staticData  *staticDataRefresher
+	// Used as the max age of HTTP responses.
+	updatePeriod time.Duration
+	clock        clock.Clock
+}
+
+// The data built by a single update of the feed.
+type feedData struct {
+	gtfs       renderedFeed
+	vehicles   renderedFeed
+	alerts     renderedFeed
+	views      *filteredViews
+	departures departures
+	// Only set if differential updates are enabled.
+	differential *differentialHistory
+	// The static and realtime data the update was built from.
+	staticData   staticData
+	realtimeData map[sourceapi.Station][]Train
+	// When the trains at each station were last retrieved from the source API.
+	stationToLastUpdated map[sourceapi.Station]time.Time
+	// Whether the static data is the fallback data because it couldn't be retrieved from the source API.
+	fallbackStaticData bool
+	// When this update was done.
+	lastUpdate      time.Time
+	stationStatuses []StationStatus
+}
+
+// FeedOption configures optional behavior of a feed.
+type FeedOption func(*feedOptions)
+
+type feedOptions struct {
+	vehiclePositionsInFeed  bool
+	alertSource             AlertSource
+	alertsInFeed            bool
+	platformResolver        *PlatformResolver
+	scheduleMatcher         *ScheduleMatcher
+	differentialMaxAge      time.Duration
+	maxDataAge              time.Duration
+	degradedStart           bool
+	fallbackGtfsStatic      *GtfsStatic
+	staticDataRefreshPeriod time.Duration
+}
+
+// WithVehiclePositionsInFeed includes the inferred vehicle positions in the main GTFS realtime data,
+// in addition to the separate vehicle positions data returned by `GetVehicles`.
+func WithVehiclePositionsInFeed() FeedOption {
+	return func(o *feedOptions) {
+		o.vehiclePositionsInFeed = true
+	}
+}
+
+// WithAlertSource makes the feed periodically retrieve service alerts from the alert source.
+// The alerts are available through `GetAlerts`.
+func WithAlertSource(alertSource AlertSource) FeedOption {
+	return func(o *feedOptions) {
+		o.alertSource = alertSource
+	}
+}
+
+// WithPlatformResolver makes the feed use platform stop IDs, as determined by the resolver,
+// instead of station stop IDs wherever the platform is known.
+func WithPlatformResolver(platformResolver *PlatformResolver) FeedOption {
+	return func(o *feedOptions) {
+		o.platformResolver = platformResolver
+	}
+}
+
+// WithScheduleMatcher makes the feed match trips to the scheduled trips in a GTFS static feed.
+// Matched trips use the scheduled trip ID and report delays relative to the schedule.
+func WithScheduleMatcher(scheduleMatcher *ScheduleMatcher) FeedOption {
+	return func(o *feedOptions) {
+		o.scheduleMatcher = scheduleMatcher
+	}
+}
+
+// WithDifferentialUpdates makes the feed serve DIFFERENTIAL messages to clients that send the header
+// timestamp of the last message they received in the LastSeenTimestampHeader request header.
+//
+// The feed keeps the versions of the feed generated within the max age. Clients that last saw an older
+// version, or that don't send the header, receive the FULL_DATASET message.
+func WithDifferentialUpdates(maxAge time.Duration) FeedOption {
+	return func(o *feedOptions) {
+		o.differentialMaxAge = maxAge
+	}
+}
+
+// WithAlertsInFeed includes the service alerts in the main GTFS realtime data,
+// in addition to the separate alerts data returned by `GetAlerts`.
+func WithAlertsInFeed() FeedOption {
+	return func(o *feedOptions) {
+		o.alertsInFeed = true
+	}
 }
 
 // UpdateCallback is the type of callback that the feed runs after each update.
@@ -54,29 +145,104 @@ type UpdateCallback func(msg *gtfs.FeedMessage, requestErrs []error)
 // NewFeed creates a new feed.
 //
 // This function gets static and realtime data from the source API and creates the
-// first version of the GTFS realtime feed before returning.
+// first version of the GTFS realtime feed before returning. Unless the feed is configured using
+// WithDegradedStart, an error is returned if any of this data can't be retrieved.
 // It then, in the background, periodically updates the realtime data following the provided
 // update period.
 //
 // After each update, including the first synchronous update, the provided callback is invoked.
-func NewFeed(ctx context.Context, clock clock.Clock, updatePeriod time.Duration, sourceClient SourceClient, callback UpdateCallback) (*Feed, error) {
-	f := Feed{}
+func NewFeed(ctx context.Context, clock clock.Clock, updatePeriod time.Duration, sourceClient SourceClient, callback UpdateCallback, opts ...FeedOption) (*Feed, error) {
+	var options feedOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	f := Feed{broadcaster: newBroadcaster(), updatePeriod: updatePeriod, clock: clock}
 	fmt.Println("Starting up")
 	staticData, err := getStaticData(ctx, sourceClient)
+	fallbackStaticData := false
 	if err != nil {
-		return nil, err
+		if !options.degradedStart {
+			return nil, err
+		}
+		fmt.Println("Failed to retrieve static data from the source API; using the fallback static data:", err)
+		staticData, err = getStaticData(ctx, newFallbackStaticDataSource(options.fallbackGtfsStatic))
+		if err != nil {
+			return nil, err
+		}
+		fallbackStaticData = true
 	}
+	staticData.platformResolver = options.platformResolver
+	initialStaticData := &staticDataVersion{data: staticData, fallback: fallbackStaticData}
+	if !fallbackStaticData {
+		initialStaticData.stats.LastRefreshed = clock.Now()
+	}
+	f.staticData = &staticDataRefresher{clock: clock, source: sourceClient, platformResolver: options.platformResolver}
+	f.staticData.current.Store(initialStaticData)
+	currentStaticData := initialStaticData
+	stopIdToStationStopId := staticData.stopIdToStationStopId()
 	realtimeData := map[sourceapi.Station][]Train{}
+	stationToLastUpdated := map[sourceapi.Station]time.Time{}
+	stationToLastError := map[sourceapi.Station]stationError{}
+	tracker := newTripTracker()
+	var differential *differentialHistory
+	if options.differentialMaxAge > 0 {
+		differential = newDifferentialHistory(options.differentialMaxAge)
+	}
+	var alerts []ServiceAlert
+	var lastAlertsAttempt time.Time
 
 	updateFunc := func() []error {
 		fmt.Println("Updating GTFS Realtime feed.")
-		requestErrs := updateRealtimeData(ctx, realtimeData, sourceClient, staticData)
-		feedMessage := buildGtfsRealtimeFeedMessage(clock, staticData, realtimeData)
-		out, err := proto.Marshal(feedMessage)
-		if err != nil {
-			panic(fmt.Sprintf("failed go generate realtime protobuf file: %s", err))
+		if version := f.staticData.current.Load(); version != currentStaticData {
+			currentStaticData = version
+			staticData = version.data
+			stopIdToStationStopId = staticData.stopIdToStationStopId()
+			removeStaleStations(staticData, realtimeData, stationToLastUpdated)
+		}
+		requestErrs := updateRealtimeData(ctx, realtimeData, stationToLastUpdated, clock.Now(), sourceClient, staticData)
+		recordStationErrors(stationToLastError, requestErrs, clock.Now())
+		expireRealtimeData(realtimeData, stationToLastUpdated, clock.Now(), options.maxDataAge)
+		trips := stitchTrips(staticData, realtimeData)
+		tracker.assignIds(trips)
+		if options.scheduleMatcher != nil {
+			options.scheduleMatcher.match(trips)
 		}
-		f.set(out)
+		feedMessage := buildGtfsRealtimeFeedMessage(clock, staticData, trips)
+		vehiclesMessage := buildVehiclePositionsFeedMessage(clock, staticData, trips)
+		if options.alertSource != nil && (lastAlertsAttempt.IsZero() || clock.Since(lastAlertsAttempt) >= alertsRefreshPeriod) {
+			lastAlertsAttempt = clock.Now()
+			newAlerts, err := options.alertSource.GetAlerts(ctx)
+			if err != nil {
+				fmt.Println("There was an error when retrieving service alerts:", err)
+			} else {
+				alerts = newAlerts
+			}
+		}
+		alertsMessage := buildAlertsFeedMessage(clock, staticData, alerts)
+		if options.vehiclePositionsInFeed {
+			feedMessage.Entity = append(feedMessage.Entity, vehiclesMessage.Entity...)
+		}
+		if options.alertsInFeed {
+			feedMessage.Entity = append(feedMessage.Entity, alertsMessage.Entity...)
+		}
+		if differential != nil {
+			differential = differential.add(feedMessage)
+		}
+		f.set(feedData{
+			gtfs:                 renderFeed(feedMessage),
+			vehicles:             renderFeed(vehiclesMessage),
+			alerts:               renderFeed(alertsMessage),
+			views:                newFilteredViews(feedMessage, stopIdToStationStopId),
+			departures:           buildDepartures(clock, staticData, trips),
+			differential:         differential,
+			staticData:           staticData,
+			realtimeData:         copyMap(realtimeData),
+			stationToLastUpdated: copyMap(stationToLastUpdated),
+			fallbackStaticData:   currentStaticData.fallback,
+			lastUpdate:           clock.Now(),
+			stationStatuses:      buildStationStatuses(staticData, realtimeData, stationToLastUpdated, stationToLastError),
+		})
+		f.broadcaster.publish(feedMessage)
 		callback(feedMessage, requestErrs)
 		fmt.Println("Finished updating")
 		return requestErrs
@@ -84,12 +250,21 @@ func NewFeed(ctx context.Context, clock clock.Clock, updatePeriod time.Duration,
 
 	errs := updateFunc()
 	if len(errs) > 0 {
-		return nil, fmt.Errorf("failed to initialize realtime data: %v", errs)
+		if !options.degradedStart {
+			return nil, fmt.Errorf("failed to initialize realtime data: %v", errs)
+		}
+		fmt.Printf("Starting in degraded mode; %d errors occurred when initializing realtime data\n", len(errs))
 	}
 	// We ensure the ticker is constructed before the function is returned; otherwise,
 	// there is a race condition between initializing the ticker and incrementing the
 	// time in the unit testing which results in a deadlock.
 	ticker := clock.Ticker(updatePeriod)
+	// While the fallback static data is in use, the static data is retrieved on each update until that succeeds.
+	if fallbackStaticData {
+		go f.staticData.run(ctx, clock.Ticker(updatePeriod), options.staticDataRefreshPeriod)
+	} else if options.staticDataRefreshPeriod > 0 {
+		go f.staticData.run(ctx, clock.Ticker(options.staticDataRefreshPeriod), options.staticDataRefreshPeriod)
+	}
 	go func() {
 		defer ticker.Stop()
 		for {
@@ -108,32 +283,149 @@ func NewFeed(ctx context.Context, clock clock.Clock, updatePeriod time.Duration,
 func (f *Feed) Get() []byte {
 	f.mutex.RLock()
 	defer f.mutex.RUnlock()
-	return f.gtfs
+	return f.data.gtfs.get(formatProto)
+}
+
+// GetVehicles returns the most recent GTFS realtime vehicle positions data.
+func (f *Feed) GetVehicles() []byte {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.data.vehicles.get(formatProto)
+}
+
+// GetAlerts returns the most recent GTFS realtime service alerts data.
+func (f *Feed) GetAlerts() []byte {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.data.alerts.get(formatProto)
 }
 
-func (f *Feed) set(b []byte) {
+func (f *Feed) set(data feedData) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
-	f.gtfs = b
+	f.data = data
+}
+
+func (f *Feed) get() feedData {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.data
 }
 
 // ServeHTTP responds to all requests with the most recent GTFS realtime data.
+//
+// The data is in the protobuf format by default. The JSON and text protobuf formats can be requested using
+// the Accept header or the `format` query parameter, which takes one of the values pb, json and text.
+//
+// The feed can be filtered using the query parameters stop_id, route_id, direction_id, max_arrivals and horizon;
+// see the README for details. Filtered feeds are always full datasets.
+//
+// Responses are compressed for clients that accept gzip or deflate, and support conditional requests using the
+// ETag and Last-Modified headers. They can be cached for the update period.
+//
+// If differential updates are enabled and the request has a last seen timestamp header,
+// the response contains only the changes since the version the client last saw.
+//
+// The DataAgeHeader response header contains the age of the oldest station data in the feed.
 func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	_, err := w.Write(f.Get())
+	data := f.get()
+	f.setDataAgeHeader(w)
+	filter, filtered, err := parseFeedFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filtered {
+		writeFeed(w, r, data.views.get(filter), f.updatePeriod)
+		return
+	}
+	if data.differential != nil {
+		w.Header().Add("Vary", LastSeenTimestampHeader)
+		if diff, ok := data.differential.getForRequest(r); ok {
+			writeFeed(w, r, diff, f.updatePeriod)
+			return
+		}
+	}
+	writeFeed(w, r, data.gtfs, f.updatePeriod)
+}
+
+// VehiclesHandler returns a handler that responds to all requests with the most recent
+// GTFS realtime vehicle positions data, in the same formats as ServeHTTP.
+func (f *Feed) VehiclesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeFeed(w, r, f.get().vehicles, f.updatePeriod)
+	})
+}
+
+// AlertsHandler returns a handler that responds to all requests with the most recent
+// GTFS realtime service alerts data, in the same formats as ServeHTTP.
+func (f *Feed) AlertsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeFeed(w, r, f.get().alerts, f.updatePeriod)
+	})
+}
+
+func writeBytes(w http.ResponseWriter, b []byte) {
+	_, err := w.Write(b)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+func mustMarshal(msg *gtfs.FeedMessage) []byte {
+	out, err := proto.Marshal(msg)
+	if err != nil {
+		panic(fmt.Sprintf("failed go generate realtime protobuf file: %s", err))
+	}
+	return out
+}
+
 // A container for the static data retrieved at the start.
 type staticData struct {
 	stations        []sourceapi.Station
 	stationToStopId map[sourceapi.Station]string
 	routeToRouteId  map[sourceapi.Route]string
+	// Optional
+	platformResolver *PlatformResolver
+}
+
+// Returns the GTFS static stop ID to use for a train of the route travelling in the direction that stops at the station.
+//
+// This is the platform stop ID if a platform resolver is configured and the platform is known, and otherwise the
+// station stop ID.
+func (s staticData) stopId(station sourceapi.Station, route sourceapi.Route, direction sourceapi.Direction) string {
+	if s.platformResolver != nil {
+		if stopId, ok := s.platformResolver.Resolve(station, direction, route); ok {
+			return stopId
+		}
+	}
+	return s.stationToStopId[station]
+}
+
+// Returns a map from each stop ID that can appear in the feed to the stop ID of its station.
+func (s staticData) stopIdToStationStopId() map[string]string {
+	result := map[string]string{}
+	for _, stopId := range s.stationToStopId {
+		result[stopId] = stopId
+	}
+	if s.platformResolver != nil {
+		for platformStopId, station := range s.platformResolver.platformToStation() {
+			if stationStopId, ok := s.stationToStopId[station]; ok {
+				result[platformStopId] = stationStopId
+			}
+		}
+	}
+	return result
+}
+
+// The methods of a source client used to get static data.
+type staticDataSource interface {
+	GetStationToStopId(context.Context) (map[sourceapi.Station]string, error)
+	GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error)
 }
 
 // Gets static data from the source API.
-func getStaticData(ctx context.Context, sourceClient SourceClient) (staticData, error) {
+func getStaticData(ctx context.Context, sourceClient staticDataSource) (staticData, error) {
 	var s staticData
 	var err error
 	s.routeToRouteId, err = sourceClient.GetRouteToRouteId(ctx)
@@ -156,8 +448,8 @@ func getStaticData(ctx context.Context, sourceClient SourceClient) (staticData,
 // Updates the realtime data using the source API.
 //
 // If data for one or more stations cannot be retrieved, the pre-existing realtime data is conservered
-// and corresponding number of errors are returned.
-func updateRealtimeData(ctx context.Context, data map[sourceapi.Station][]Train, sourceClient SourceClient, staticData staticData) []error {
+// and corresponding number of errors are returned. For the other stations, the last updated time is set to now.
+func updateRealtimeData(ctx context.Context, data map[sourceapi.Station][]Train, stationToLastUpdated map[sourceapi.Station]time.Time, now time.Time, sourceClient SourceClient, staticData staticData) []error {
 	type trainsAtStation struct {
 		Station sourceapi.Station
 		Trains  []Train
@@ -176,82 +468,79 @@ func updateRealtimeData(ctx context.Context, data map[sourceapi.Station][]Train,
 	for range staticData.stationToStopId {
 		trainsAtStation := <-allTrainsAtStations
 		if trainsAtStation.Err != nil {
-			errs = append(errs, trainsAtStation.Err)
+			errs = append(errs, &stationRequestError{station: trainsAtStation.Station, err: trainsAtStation.Err})
 			fmt.Println("There was an error when retrieving data for station",
 				staticData.stationToStopId[trainsAtStation.Station])
 			continue
 		}
 		data[trainsAtStation.Station] = trainsAtStation.Trains
+		stationToLastUpdated[trainsAtStation.Station] = now
 	}
 	return errs
 }
 
-// Build a GTFS Realtime message from a snapshot of the current data.
-func buildGtfsRealtimeFeedMessage(clock clock.Clock, staticData staticData, realtimeData map[sourceapi.Station][]Train) *gtfs.FeedMessage {
-	directionToBoolean := func(direction sourceapi.Direction) *uint32 {
-		var result uint32
-		if direction == sourceapi.Direction_TO_NY {
-			result = 1
-		} else if direction == sourceapi.Direction_TO_NJ {
-			result = 0
-		}
-		return &result
-	}
+// Build a GTFS Realtime message from the trips built from a snapshot of the current data.
+func buildGtfsRealtimeFeedMessage(clock clock.Clock, staticData staticData, trips []*trip) *gtfs.FeedMessage {
 	timestamppbToInt64 := func(t *timestamppb.Timestamp) *int64 {
 		if t != nil {
 			return ptr(t.Seconds)
 		}
 		return nil
 	}
-	timestamppbToUint64 := func(t *timestamppb.Timestamp) *uint64 {
-		if t != nil {
-			return ptr(uint64(t.Seconds))
-		}
-		return nil
-	}
 	var entities []*gtfs.FeedEntity
-	for _, apiStationId := range staticData.stations {
-		trains := realtimeData[apiStationId]
-		for _, train := range trains {
-			routeID, ok := staticData.routeToRouteId[train.Route]
-			if !ok {
-				continue
-			}
-			if train.Direction != sourceapi.Direction_TO_NJ && train.Direction != sourceapi.Direction_TO_NY {
-				continue
-			}
-			if train.ProjectedArrival == nil {
-				continue
-			}
-			if train.LastUpdated == nil {
-				continue
-			}
-			update := &gtfs.TripUpdate{
-				Trip: &gtfs.TripDescriptor{
-					RouteId:     &routeID,
-					DirectionId: directionToBoolean(train.Direction),
-				},
-				StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
-					{
-						StopId: ptr(staticData.stationToStopId[apiStationId]),
-						Arrival: &gtfs.TripUpdate_StopTimeEvent{
-							Time: timestamppbToInt64(train.ProjectedArrival),
-						},
-					},
-				},
-				Timestamp: timestamppbToUint64(train.LastUpdated),
+	for _, trip := range trips {
+		update := &gtfs.TripUpdate{
+			Trip:      buildTripDescriptor(staticData, trip),
+			Timestamp: ptr(uint64(trip.lastUpdated())),
+		}
+		for _, stop := range trip.stops {
+			arrival := &gtfs.TripUpdate_StopTimeEvent{
+				Time: timestamppbToInt64(stop.train.ProjectedArrival),
 			}
-			b, err := json.Marshal(update)
-			if err != nil {
-				panic(err)
+			if delay, ok := trip.schedule.delay(stop); ok {
+				arrival.Delay = ptr(int32(delay.Seconds()))
 			}
-			update.Trip.TripId = ptr(fmt.Sprintf("%x", md5.Sum(b)))
-			entities = append(entities, &gtfs.FeedEntity{
-				Id:         update.Trip.TripId,
-				TripUpdate: update,
+			update.StopTimeUpdate = append(update.StopTimeUpdate, &gtfs.TripUpdate_StopTimeUpdate{
+				StopId:  ptr(staticData.stopId(stop.station, trip.route, trip.direction)),
+				Arrival: arrival,
 			})
 		}
+		// The entity ID is always the tracked trip ID, even for scheduled trips, so that it doesn't change
+		// as the trip is matched to a scheduled trip and unmatched again.
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:         ptr(trip.id),
+			TripUpdate: update,
+		})
 	}
+	return newFeedMessage(clock, entities)
+}
+
+func buildTripDescriptor(staticData staticData, trip *trip) *gtfs.TripDescriptor {
+	directionToBoolean := func(direction sourceapi.Direction) *uint32 {
+		var result uint32
+		if direction == sourceapi.Direction_TO_NY {
+			result = 1
+		} else if direction == sourceapi.Direction_TO_NJ {
+			result = 0
+		}
+		return &result
+	}
+	descriptor := &gtfs.TripDescriptor{
+		TripId:      ptr(trip.id),
+		RouteId:     ptr(staticData.routeToRouteId[trip.route]),
+		DirectionId: directionToBoolean(trip.direction),
+	}
+	if trip.schedule != nil {
+		descriptor.ScheduleRelationship = trip.schedule.relationship.Enum()
+		if trip.schedule.relationship == gtfs.TripDescriptor_SCHEDULED {
+			descriptor.TripId = ptr(trip.schedule.tripId)
+			descriptor.StartDate = ptr(trip.schedule.startDate)
+		}
+	}
+	return descriptor
+}
+
+func newFeedMessage(clock clock.Clock, entities []*gtfs.FeedEntity) *gtfs.FeedMessage {
 	return &gtfs.FeedMessage{
 		Header: &gtfs.FeedHeader{
 			GtfsRealtimeVersion: ptr("0.2"),
@@ -262,6 +551,14 @@ func buildGtfsRealtimeFeedMessage(clock clock.Clock, staticData staticData, real
 	}
 }
 
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	result := make(map[K]V, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
 func ptr[T any](t T) *T {
 	return &t
 }
@@ -0,0 +1,186 @@
+package pathgtfsrt
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// PlatformOverride specifies the platform stop that trains of a route travelling in a direction
+// use at a station, overriding the platform derived from the GTFS static feed.
+type PlatformOverride struct {
+	Station   sourceapi.Station
+	Direction sourceapi.Direction
+	// If ROUTE_UNSPECIFIED, the override applies to all routes.
+	Route  sourceapi.Route
+	StopId string
+}
+
+// ParsePlatformOverrides parses platform overrides from CSV data.
+//
+// Each line has four columns: the station, the direction, the route and the GTFS static stop ID of the
+// platform. Stations, directions and routes are given using their source API names (e.g. HOBOKEN, TO_NY
+// and HOB_33). The route may be `*` to apply the override to all routes. Empty lines and lines starting
+// with `#` are ignored.
+func ParsePlatformOverrides(r io.Reader) ([]PlatformOverride, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+	var overrides []PlatformOverride
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return overrides, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		station, ok := sourceapi.Station_value[record[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown station %q in platform override", record[0])
+		}
+		direction, ok := sourceapi.Direction_value[record[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown direction %q in platform override", record[1])
+		}
+		var route int32
+		if record[2] != "*" {
+			route, ok = sourceapi.Route_value[record[2]]
+			if !ok {
+				return nil, fmt.Errorf("unknown route %q in platform override", record[2])
+			}
+		}
+		overrides = append(overrides, PlatformOverride{
+			Station:   sourceapi.Station(station),
+			Direction: sourceapi.Direction(direction),
+			Route:     sourceapi.Route(route),
+			StopId:    strings.TrimSpace(record[3]),
+		})
+	}
+}
+
+// LoadPlatformOverrides reads platform overrides from a CSV file in the format described in ParsePlatformOverrides.
+func LoadPlatformOverrides(path string) ([]PlatformOverride, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePlatformOverrides(f)
+}
+
+type platformKey struct {
+	station   sourceapi.Station
+	direction sourceapi.Direction
+	route     sourceapi.Route
+}
+
+// PlatformResolver determines the platform stop at which a train stops, given its station, direction and route.
+//
+// The platforms are derived from the stopping patterns in a GTFS static feed: for each station, direction and
+// route, the platform used by the most scheduled trips is chosen.
+type PlatformResolver struct {
+	platforms map[platformKey]string
+	overrides map[platformKey]string
+}
+
+// NewPlatformResolver creates a platform resolver from a GTFS static feed. An error is returned if an override
+// refers to a stop that isn't in the feed, or to a platform of a different station.
+func NewPlatformResolver(gtfsStatic *GtfsStatic, overrides []PlatformOverride) (*PlatformResolver, error) {
+	stopIdToStation := map[string]sourceapi.Station{}
+	for station, stopId := range gtfsStatic.stationToStopId {
+		stopIdToStation[stopId] = station
+	}
+	routeIdToRoute := map[string]sourceapi.Route{}
+	for route, routeId := range gtfsStatic.routeToRouteId {
+		routeIdToRoute[routeId] = route
+	}
+	counts := map[platformKey]map[string]int{}
+	for tripId, trip := range gtfsStatic.trips {
+		route, ok := routeIdToRoute[trip.routeId]
+		if !ok {
+			continue
+		}
+		direction, ok := gtfsStatic.direction(trip.directionId)
+		if !ok {
+			continue
+		}
+		for _, stopTime := range gtfsStatic.stopTimes[tripId] {
+			stop, ok := gtfsStatic.stops[stopTime.stopId]
+			if !ok || stop.parentStation == "" {
+				continue
+			}
+			station, ok := stopIdToStation[stop.parentStation]
+			if !ok {
+				continue
+			}
+			key := platformKey{station: station, direction: direction, route: route}
+			if counts[key] == nil {
+				counts[key] = map[string]int{}
+			}
+			counts[key][stop.id]++
+		}
+	}
+	resolver := &PlatformResolver{
+		platforms: map[platformKey]string{},
+		overrides: map[platformKey]string{},
+	}
+	for key, stopIdToCount := range counts {
+		var stopIds []string
+		for stopId := range stopIdToCount {
+			stopIds = append(stopIds, stopId)
+		}
+		sort.Slice(stopIds, func(i, j int) bool {
+			if stopIdToCount[stopIds[i]] != stopIdToCount[stopIds[j]] {
+				return stopIdToCount[stopIds[i]] > stopIdToCount[stopIds[j]]
+			}
+			return stopIds[i] < stopIds[j]
+		})
+		resolver.platforms[key] = stopIds[0]
+	}
+	for _, override := range overrides {
+		stop, ok := gtfsStatic.stops[override.StopId]
+		if !ok {
+			return nil, fmt.Errorf("platform override for %s refers to unknown stop %q", override.Station, override.StopId)
+		}
+		if station, ok := stopIdToStation[stop.parentStation]; ok && station != override.Station {
+			return nil, fmt.Errorf("platform override for %s refers to stop %q, which is a platform of %s", override.Station, override.StopId, station)
+		}
+		key := platformKey{station: override.Station, direction: override.Direction, route: override.Route}
+		resolver.overrides[key] = override.StopId
+	}
+	return resolver, nil
+}
+
+// Resolve returns the GTFS static stop ID of the platform, if it is known.
+//
+// Overrides for the specific route take precedence over overrides for all routes, which in turn take
+// precedence over the platforms derived from the GTFS static feed.
+func (resolver *PlatformResolver) Resolve(station sourceapi.Station, direction sourceapi.Direction, route sourceapi.Route) (string, bool) {
+	key := platformKey{station: station, direction: direction, route: route}
+	if stopId, ok := resolver.overrides[key]; ok {
+		return stopId, true
+	}
+	if stopId, ok := resolver.overrides[platformKey{station: station, direction: direction}]; ok {
+		return stopId, true
+	}
+	stopId, ok := resolver.platforms[key]
+	return stopId, ok
+}
+
+// Returns a map from each platform stop ID that the resolver can return to the station of the platform.
+func (resolver *PlatformResolver) platformToStation() map[string]sourceapi.Station {
+	result := map[string]sourceapi.Station{}
+	for _, m := range []map[platformKey]string{resolver.platforms, resolver.overrides} {
+		for key, stopId := range m {
+			result[stopId] = key.station
+		}
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package pathgtfsrt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestPlatformResolver(t *testing.T) {
+	overrides, err := ParsePlatformOverrides(strings.NewReader(`# station,direction,route,stop_id
+GROVE_STREET,TO_NY,JSQ_33,26728-2
+GROVE_STREET,TO_NJ,*,26728-1
+`))
+	if err != nil {
+		t.Fatalf("ParsePlatformOverrides() err got=%v, want=<nil>", err)
+	}
+	resolver, err := NewPlatformResolver(loadTestGtfsStatic(t), overrides)
+	if err != nil {
+		t.Fatalf("NewPlatformResolver() err got=%v, want=<nil>", err)
+	}
+	for _, tc := range []struct {
+		name       string
+		station    sourceapi.Station
+		direction  sourceapi.Direction
+		route      sourceapi.Route
+		wantStopId string
+		wantOk     bool
+	}{
+		{
+			name:       "derived from GTFS static, to NY",
+			station:    sourceapi.Station_HOBOKEN,
+			direction:  sourceapi.Direction_TO_NY,
+			route:      sourceapi.Route_HOB_33,
+			wantStopId: "26730-1",
+			wantOk:     true,
+		},
+		{
+			name:       "derived from GTFS static, to NJ",
+			station:    sourceapi.Station_JOURNAL_SQUARE,
+			direction:  sourceapi.Direction_TO_NJ,
+			route:      sourceapi.Route_NWK_WTC,
+			wantStopId: "26731-2",
+			wantOk:     true,
+		},
+		{
+			name:       "derived from GTFS static, route specific platform",
+			station:    sourceapi.Station_HOBOKEN,
+			direction:  sourceapi.Direction_TO_NY,
+			route:      sourceapi.Route_HOB_WTC,
+			wantStopId: "26730-3",
+			wantOk:     true,
+		},
+		{
+			name:       "route override",
+			station:    sourceapi.Station_GROVE_STREET,
+			direction:  sourceapi.Direction_TO_NY,
+			route:      sourceapi.Route_JSQ_33,
+			wantStopId: "26728-2",
+			wantOk:     true,
+		},
+		{
+			name:       "route override for different route",
+			station:    sourceapi.Station_GROVE_STREET,
+			direction:  sourceapi.Direction_TO_NY,
+			route:      sourceapi.Route_NWK_WTC,
+			wantStopId: "26728-1",
+			wantOk:     true,
+		},
+		{
+			name:       "all routes override",
+			station:    sourceapi.Station_GROVE_STREET,
+			direction:  sourceapi.Direction_TO_NJ,
+			route:      sourceapi.Route_NWK_WTC,
+			wantStopId: "26728-1",
+			wantOk:     true,
+		},
+		{
+			name:      "route does not stop at station",
+			station:   sourceapi.Station_NEWARK,
+			direction: sourceapi.Direction_TO_NY,
+			route:     sourceapi.Route_HOB_33,
+			wantOk:    false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stopId, ok := resolver.Resolve(tc.station, tc.direction, tc.route)
+
+			if stopId != tc.wantStopId || ok != tc.wantOk {
+				t.Errorf("Resolve() got=(%q, %t), want=(%q, %t)", stopId, ok, tc.wantStopId, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestNewPlatformResolver_InvalidOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		override PlatformOverride
+	}{
+		{
+			name: "unknown stop",
+			override: PlatformOverride{
+				Station:   sourceapi.Station_GROVE_STREET,
+				Direction: sourceapi.Direction_TO_NY,
+				StopId:    "unknown",
+			},
+		},
+		{
+			name: "platform of another station",
+			override: PlatformOverride{
+				Station:   sourceapi.Station_GROVE_STREET,
+				Direction: sourceapi.Direction_TO_NY,
+				StopId:    "26730-1",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewPlatformResolver(loadTestGtfsStatic(t), []PlatformOverride{tc.override})
+
+			if err == nil {
+				t.Errorf("NewPlatformResolver() err got=<nil>, want=<non-nil>")
+			}
+		})
+	}
+}
+
+func TestParsePlatformOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		data          string
+		wantOverrides []PlatformOverride
+		wantErr       bool
+	}{
+		{
+			name: "valid",
+			data: "HOBOKEN, TO_NY, HOB_33, stopID1\n\nNEWARK,TO_NY,*,stopID2\n",
+			wantOverrides: []PlatformOverride{
+				{
+					Station:   sourceapi.Station_HOBOKEN,
+					Direction: sourceapi.Direction_TO_NY,
+					Route:     sourceapi.Route_HOB_33,
+					StopId:    "stopID1",
+				},
+				{
+					Station:   sourceapi.Station_NEWARK,
+					Direction: sourceapi.Direction_TO_NY,
+					StopId:    "stopID2",
+				},
+			},
+		},
+		{
+			name:    "unknown station",
+			data:    "NOWHERE,TO_NY,HOB_33,stopID1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown direction",
+			data:    "HOBOKEN,UP,HOB_33,stopID1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown route",
+			data:    "HOBOKEN,TO_NY,HOB_NWK,stopID1\n",
+			wantErr: true,
+		},
+		{
+			name:    "wrong number of columns",
+			data:    "HOBOKEN,TO_NY,stopID1\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides, err := ParsePlatformOverrides(strings.NewReader(tc.data))
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParsePlatformOverrides() err got=%v, wantErr=%t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantOverrides, overrides); diff != "" {
+				t.Errorf("ParsePlatformOverrides() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: feed.proto
+
+package path_train_gtfsrtv1
+
+import (
+	context "context"
+	gtfsrt "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// FeedServiceClient is the client API for FeedService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FeedServiceClient interface {
+	// Gets the most recent GTFS realtime message.
+	GetFeed(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*gtfsrt.FeedMessage, error)
+	// Streams the GTFS realtime message built in each update of the feed, starting with the most
+	// recent message. Clients that fall behind skip messages and always receive the latest one.
+	StreamFeed(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (FeedService_StreamFeedClient, error)
+}
+
+type feedServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFeedServiceClient(cc grpc.ClientConnInterface) FeedServiceClient {
+	return &feedServiceClient{cc}
+}
+
+func (c *feedServiceClient) GetFeed(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*gtfsrt.FeedMessage, error) {
+	out := new(gtfsrt.FeedMessage)
+	err := c.cc.Invoke(ctx, "/path_train_gtfsrt.v1.FeedService/GetFeed", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *feedServiceClient) StreamFeed(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (FeedService_StreamFeedClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FeedService_ServiceDesc.Streams[0], "/path_train_gtfsrt.v1.FeedService/StreamFeed", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &feedServiceStreamFeedClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FeedService_StreamFeedClient interface {
+	Recv() (*gtfsrt.FeedMessage, error)
+	grpc.ClientStream
+}
+
+type feedServiceStreamFeedClient struct {
+	grpc.ClientStream
+}
+
+func (x *feedServiceStreamFeedClient) Recv() (*gtfsrt.FeedMessage, error) {
+	m := new(gtfsrt.FeedMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FeedServiceServer is the server API for FeedService service.
+// All implementations should embed UnimplementedFeedServiceServer
+// for forward compatibility
+type FeedServiceServer interface {
+	// Gets the most recent GTFS realtime message.
+	GetFeed(context.Context, *emptypb.Empty) (*gtfsrt.FeedMessage, error)
+	// Streams the GTFS realtime message built in each update of the feed, starting with the most
+	// recent message. Clients that fall behind skip messages and always receive the latest one.
+	StreamFeed(*emptypb.Empty, FeedService_StreamFeedServer) error
+}
+
+// UnimplementedFeedServiceServer should be embedded to have forward compatible implementations.
+type UnimplementedFeedServiceServer struct {
+}
+
+func (UnimplementedFeedServiceServer) GetFeed(context.Context, *emptypb.Empty) (*gtfsrt.FeedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFeed not implemented")
+}
+func (UnimplementedFeedServiceServer) StreamFeed(*emptypb.Empty, FeedService_StreamFeedServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFeed not implemented")
+}
+
+// UnsafeFeedServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FeedServiceServer will
+// result in compilation errors.
+type UnsafeFeedServiceServer interface {
+	mustEmbedUnimplementedFeedServiceServer()
+}
+
+func RegisterFeedServiceServer(s grpc.ServiceRegistrar, srv FeedServiceServer) {
+	s.RegisterService(&FeedService_ServiceDesc, srv)
+}
+
+func _FeedService_GetFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FeedServiceServer).GetFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/path_train_gtfsrt.v1.FeedService/GetFeed",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FeedServiceServer).GetFeed(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FeedService_StreamFeed_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FeedServiceServer).StreamFeed(m, &feedServiceStreamFeedServer{stream})
+}
+
+type FeedService_StreamFeedServer interface {
+	Send(*gtfsrt.FeedMessage) error
+	grpc.ServerStream
+}
+
+type feedServiceStreamFeedServer struct {
+	grpc.ServerStream
+}
+
+func (x *feedServiceStreamFeedServer) Send(m *gtfsrt.FeedMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// FeedService_ServiceDesc is the grpc.ServiceDesc for FeedService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FeedService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "path_train_gtfsrt.v1.FeedService",
+	HandlerType: (*FeedServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetFeed",
+			Handler:    _FeedService_GetFeed_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFeed",
+			Handler:       _FeedService_StreamFeed_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "feed.proto",
+}
@@ -0,0 +1,373 @@
+package pathgtfsrt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The methods of the source client in recordings.
+const (
+	recordedStations = "stations"
+	recordedRoutes   = "routes"
+	recordedTrains   = "trains"
+)
+
+// The prefix and extension of recording files. Only files with both are rotated or replayed, so other files in
+// the recording directory are left alone.
+const (
+	recordingFilePrefix    = "source-"
+	recordingFileExtension = ".jsonl"
+)
+
+// A single response of a source client in a recording. Recordings are files containing one response per line in JSON.
+type recordedResponse struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// The source API name of the station, for trains responses.
+	Station string `json:"station,omitempty"`
+	// The JSON encoded GetUpcomingTrainsResponse, for trains responses.
+	Trains          json.RawMessage   `json:"trains,omitempty"`
+	StationToStopId map[string]string `json:"station_to_stop_id,omitempty"`
+	RouteToRouteId  map[string]string `json:"route_to_route_id,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// RecordingSourceClient is a source client that passes requests through to another source client and records
+// every response, along with the time it was received, in a log that can be played back using a ReplaySourceClient.
+//
+// The log is written to files in a directory. A new file is started when the current file reaches the maximum file
+// size, and the oldest files are deleted so that there are at most the maximum number of files.
+type RecordingSourceClient struct {
+	sourceClient SourceClient
+	clock        clock.Clock
+	dir          string
+	maxFileBytes int64
+	maxFiles     int
+
+	mutex     sync.Mutex
+	file      *os.File
+	fileBytes int64
+}
+
+// NewRecordingSourceClient creates a new recording source client writing to the directory, which is created if
+// it doesn't exist. A max file size or number of files of zero means no limit.
+func NewRecordingSourceClient(sourceClient SourceClient, clock clock.Clock, dir string, maxFileBytes int64, maxFiles int) (*RecordingSourceClient, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &RecordingSourceClient{
+		sourceClient: sourceClient,
+		clock:        clock,
+		dir:          dir,
+		maxFileBytes: maxFileBytes,
+		maxFiles:     maxFiles,
+	}, nil
+}
+
+func (c *RecordingSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	stationToStopId, err := c.sourceClient.GetStationToStopId(ctx)
+	response := recordedResponse{Method: recordedStations, StationToStopId: map[string]string{}}
+	for station, stopId := range stationToStopId {
+		response.StationToStopId[station.String()] = stopId
+	}
+	c.record(response, err)
+	return stationToStopId, err
+}
+
+func (c *RecordingSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	routeToRouteId, err := c.sourceClient.GetRouteToRouteId(ctx)
+	response := recordedResponse{Method: recordedRoutes, RouteToRouteId: map[string]string{}}
+	for route, routeId := range routeToRouteId {
+		response.RouteToRouteId[route.String()] = routeId
+	}
+	c.record(response, err)
+	return routeToRouteId, err
+}
+
+func (c *RecordingSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	trains, err := c.sourceClient.GetTrainsAtStation(ctx, station)
+	message := &sourceapi.GetUpcomingTrainsResponse{}
+	for _, train := range trains {
+		message.UpcomingTrains = append(message.UpcomingTrains, train)
+	}
+	b, marshalErr := protojson.Marshal(message)
+	if marshalErr != nil {
+		fmt.Println("Failed to record trains:", marshalErr)
+		return trains, err
+	}
+	c.record(recordedResponse{Method: recordedTrains, Station: station.String(), Trains: b}, err)
+	return trains, err
+}
+
+// Close closes the current recording file.
+func (c *RecordingSourceClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Records the response. Errors writing the recording are logged rather than returned, so that they don't affect
+// the feed.
+func (c *RecordingSourceClient) record(response recordedResponse, err error) {
+	response.Time = c.clock.Now().UTC()
+	if err != nil {
+		response.Error = err.Error()
+	}
+	b, marshalErr := json.Marshal(response)
+	if marshalErr != nil {
+		fmt.Println("Failed to record source API response:", marshalErr)
+		return
+	}
+	b = append(b, '\n')
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if writeErr := c.write(b); writeErr != nil {
+		fmt.Println("Failed to record source API response:", writeErr)
+	}
+}
+
+func (c *RecordingSourceClient) write(b []byte) error {
+	if c.file != nil && c.maxFileBytes > 0 && c.fileBytes+int64(len(b)) > c.maxFileBytes {
+		if err := c.file.Close(); err != nil {
+			return err
+		}
+		c.file = nil
+	}
+	if c.file == nil {
+		name := recordingFilePrefix + c.clock.Now().UTC().Format("20060102T150405.000000000") + recordingFileExtension
+		file, err := os.OpenFile(filepath.Join(c.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		c.file = file
+		c.fileBytes = 0
+		if err := c.deleteOldFiles(); err != nil {
+			return err
+		}
+	}
+	n, err := c.file.Write(b)
+	c.fileBytes += int64(n)
+	return err
+}
+
+// Deletes the oldest recording files so that there are at most the maximum number of files.
+func (c *RecordingSourceClient) deleteOldFiles() error {
+	if c.maxFiles <= 0 {
+		return nil
+	}
+	paths, err := recordingFiles(c.dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > c.maxFiles {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// Returns the recording files in the directory, oldest first.
+func recordingFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, recordingFilePrefix+"*"+recordingFileExtension))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReplaySourceClient is a source client that plays back a recording made using a RecordingSourceClient.
+//
+// Playback starts at the time of the first recorded response when the client is created, and proceeds at the
+// playback speed relative to the clock. Each request returns the most recent recorded response at the current
+// playback time. The times in the returned trains are shifted by the difference between the clock and the playback
+// time, so that the recorded data appears current; when using a mock clock set to the time of the first recorded
+// response and a playback speed of 1, the times are unchanged.
+type ReplaySourceClient struct {
+	clock          clock.Clock
+	speed          float64
+	start          time.Time
+	recordingStart time.Time
+	// The responses for each method, and for trains responses each station, in time order.
+	keyToResponses map[string][]recordedResponse
+}
+
+// NewReplaySourceClient creates a new replay source client from recording files, or directories containing
+// recording files.
+func NewReplaySourceClient(clock clock.Clock, speed float64, paths ...string) (*ReplaySourceClient, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("invalid playback speed %v; must be positive", speed)
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := recordingFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	var responses []recordedResponse
+	for _, file := range files {
+		fileResponses, err := readRecording(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recording %s: %w", file, err)
+		}
+		responses = append(responses, fileResponses...)
+	}
+	if len(responses) == 0 {
+		return nil, errors.New("the recording is empty")
+	}
+	sort.SliceStable(responses, func(i, j int) bool {
+		return responses[i].Time.Before(responses[j].Time)
+	})
+	c := &ReplaySourceClient{
+		clock:          clock,
+		speed:          speed,
+		start:          clock.Now(),
+		recordingStart: responses[0].Time,
+		keyToResponses: map[string][]recordedResponse{},
+	}
+	for _, response := range responses {
+		key := response.Method + "/" + response.Station
+		c.keyToResponses[key] = append(c.keyToResponses[key], response)
+	}
+	return c, nil
+}
+
+func readRecording(path string) ([]recordedResponse, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var responses []recordedResponse
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var response recordedResponse
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, scanner.Err()
+}
+
+// ReplayTime returns the current playback time.
+func (c *ReplaySourceClient) ReplayTime() time.Time {
+	elapsed := c.clock.Since(c.start)
+	return c.recordingStart.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// Returns the most recent response for the method and station at the current playback time.
+//
+// The first response is returned if there is no response yet, so that the feed can start up: recordings start with
+// the static data responses, which are made before any trains responses.
+func (c *ReplaySourceClient) response(method string, station string) (recordedResponse, error) {
+	responses := c.keyToResponses[method+"/"+station]
+	replayTime := c.ReplayTime()
+	i := sort.Search(len(responses), func(i int) bool {
+		return responses[i].Time.After(replayTime)
+	})
+	if i == 0 {
+		if len(responses) == 0 {
+			return recordedResponse{}, fmt.Errorf("no recorded %s response at %s", method, replayTime)
+		}
+		i = 1
+	}
+	response := responses[i-1]
+	if response.Error != "" {
+		return recordedResponse{}, fmt.Errorf("recorded error: %s", response.Error)
+	}
+	return response, nil
+}
+
+func (c *ReplaySourceClient) GetStationToStopId(context.Context) (map[sourceapi.Station]string, error) {
+	response, err := c.response(recordedStations, "")
+	if err != nil {
+		return nil, err
+	}
+	stationToStopId := map[sourceapi.Station]string{}
+	for station, stopId := range response.StationToStopId {
+		value, ok := sourceapi.Station_value[station]
+		if !ok {
+			return nil, fmt.Errorf("unknown station %q in recording", station)
+		}
+		stationToStopId[sourceapi.Station(value)] = stopId
+	}
+	return stationToStopId, nil
+}
+
+func (c *ReplaySourceClient) GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error) {
+	response, err := c.response(recordedRoutes, "")
+	if err != nil {
+		return nil, err
+	}
+	routeToRouteId := map[sourceapi.Route]string{}
+	for route, routeId := range response.RouteToRouteId {
+		value, ok := sourceapi.Route_value[route]
+		if !ok {
+			return nil, fmt.Errorf("unknown route %q in recording", route)
+		}
+		routeToRouteId[sourceapi.Route(value)] = routeId
+	}
+	return routeToRouteId, nil
+}
+
+func (c *ReplaySourceClient) GetTrainsAtStation(_ context.Context, station sourceapi.Station) ([]Train, error) {
+	response, err := c.response(recordedTrains, station.String())
+	if err != nil {
+		return nil, err
+	}
+	message := &sourceapi.GetUpcomingTrainsResponse{}
+	if err := protojson.Unmarshal(response.Trains, message); err != nil {
+		return nil, err
+	}
+	shift := c.clock.Now().Sub(c.ReplayTime())
+	shiftTimestamp := func(t *timestamppb.Timestamp) *timestamppb.Timestamp {
+		if t == nil {
+			return nil
+		}
+		return timestamppb.New(t.AsTime().Add(shift))
+	}
+	var trains []Train
+	for _, train := range message.UpcomingTrains {
+		train.ProjectedArrival = shiftTimestamp(train.ProjectedArrival)
+		train.LastUpdated = shiftTimestamp(train.LastUpdated)
+		trains = append(trains, train)
+	}
+	return trains, nil
+}
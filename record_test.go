@@ -0,0 +1,231 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	trains1 := []Train{sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)}
+	trains2 := []Train{sourceTrain(sourceapi.Route_HOB_WTC, sourceapi.Direction_TO_NJ, 7, 1)}
+	source := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+		routeToRouteID:  map[sourceapi.Route]string{sourceapi.Route_HOB_33: routeID1},
+		stationToTrains: map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: trains1},
+	}
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	recorder, err := NewRecordingSourceClient(source, c, dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRecordingSourceClient() err got=%v, want=<nil>", err)
+	}
+	recorder.GetStationToStopId(ctx)
+	recorder.GetRouteToRouteId(ctx)
+	c.Add(time.Second)
+	recorder.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	c.Add(time.Minute)
+	source.stationToTrains[sourceapi.Station_HOBOKEN] = trains2
+	recorder.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	c.Add(time.Minute)
+	recorder.GetTrainsAtStation(ctx, sourceapi.Station_NEWARK)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() err got=%v, want=<nil>", err)
+	}
+
+	t.Run("static data", func(t *testing.T) {
+		replay, err := NewReplaySourceClient(clock.NewMock(), 1, dir)
+		if err != nil {
+			t.Fatalf("NewReplaySourceClient() err got=%v, want=<nil>", err)
+		}
+		stationToStopId, err := replay.GetStationToStopId(ctx)
+		if err != nil {
+			t.Fatalf("GetStationToStopId() err got=%v, want=<nil>", err)
+		}
+		if diff := cmp.Diff(source.stationToStopID, stationToStopId); diff != "" {
+			t.Errorf("GetStationToStopId() mismatch (-want +got):\n%s", diff)
+		}
+		routeToRouteId, err := replay.GetRouteToRouteId(ctx)
+		if err != nil {
+			t.Fatalf("GetRouteToRouteId() err got=%v, want=<nil>", err)
+		}
+		if diff := cmp.Diff(source.routeToRouteID, routeToRouteId); diff != "" {
+			t.Errorf("GetRouteToRouteId() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("trains", func(t *testing.T) {
+		replayClock := clock.NewMock()
+		replayClock.Set(makeTime(0))
+		replay, err := NewReplaySourceClient(replayClock, 1, dir)
+		if err != nil {
+			t.Fatalf("NewReplaySourceClient() err got=%v, want=<nil>", err)
+		}
+		for _, tc := range []struct {
+			name    string
+			advance time.Duration
+			station sourceapi.Station
+			want    []Train
+			wantErr bool
+		}{
+			{
+				name:    "before the first response",
+				station: sourceapi.Station_HOBOKEN,
+				want:    trains1,
+			},
+			{
+				name:    "first response",
+				advance: time.Second,
+				station: sourceapi.Station_HOBOKEN,
+				want:    trains1,
+			},
+			{
+				name:    "second response",
+				advance: time.Minute,
+				station: sourceapi.Station_HOBOKEN,
+				want:    trains2,
+			},
+			{
+				name:    "recorded error",
+				advance: time.Minute,
+				station: sourceapi.Station_NEWARK,
+				wantErr: true,
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				replayClock.Add(tc.advance)
+
+				got, err := replay.GetTrainsAtStation(ctx, tc.station)
+
+				if gotErr := err != nil; gotErr != tc.wantErr {
+					t.Fatalf("GetTrainsAtStation() err got=%v, wantErr=%t", err, tc.wantErr)
+				}
+				if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+					t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("speed and time shift", func(t *testing.T) {
+		replayClock := clock.NewMock()
+		replayClock.Set(makeTime(100))
+		replay, err := NewReplaySourceClient(replayClock, 2, dir)
+		if err != nil {
+			t.Fatalf("NewReplaySourceClient() err got=%v, want=<nil>", err)
+		}
+		replayClock.Add(31 * time.Second)
+
+		if got, want := replay.ReplayTime(), makeTime(0).Add(62*time.Second); !got.Equal(want) {
+			t.Errorf("ReplayTime() got=%s, want=%s", got, want)
+		}
+		got, err := replay.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+		if err != nil {
+			t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+		}
+		// The recorded times are shifted by the difference between the clock and the playback time.
+		shift := 100*time.Minute - 31*time.Second
+		want := []Train{sourceTrain(sourceapi.Route_HOB_WTC, sourceapi.Direction_TO_NJ, 7, 1)}
+		want[0].ProjectedArrival = timestamppb.New(makeTime(7).Add(shift))
+		want[0].LastUpdated = timestamppb.New(makeTime(1).Add(shift))
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestNewFeed_Replay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+		routeToRouteID:  map[sourceapi.Route]string{sourceapi.Route_HOB_33: routeID1},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+		},
+	}
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	recorder, err := NewRecordingSourceClient(source, c, dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRecordingSourceClient() err got=%v, want=<nil>", err)
+	}
+	// As in the feed, the static data is retrieved before the trains.
+	recorder.GetStationToStopId(ctx)
+	recorder.GetRouteToRouteId(ctx)
+	c.Add(time.Second)
+	recorder.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() err got=%v, want=<nil>", err)
+	}
+	replayClock := clock.NewMock()
+	replayClock.Set(makeTime(0))
+	replay, err := NewReplaySourceClient(replayClock, 1, dir)
+	if err != nil {
+		t.Fatalf("NewReplaySourceClient() err got=%v, want=<nil>", err)
+	}
+	numEntities := make(chan int, 1)
+
+	_, err = NewFeed(ctx, replayClock, 5*time.Second, replay, func(msg *gtfsrt.FeedMessage, _ []error) {
+		numEntities <- len(msg.Entity)
+	})
+
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	if got := <-numEntities; got != 1 {
+		t.Errorf("number of entities got=%d, want=1", got)
+	}
+}
+
+func TestRecordingSourceClient_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	c := clock.NewMock()
+	source := &mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken},
+	}
+	// Files in the directory that weren't written by the recorder must not be rotated.
+	otherFile := filepath.Join(dir, "other.jsonl")
+	if err := os.WriteFile(otherFile, nil, 0644); err != nil {
+		t.Fatalf("os.WriteFile() err got=%v, want=<nil>", err)
+	}
+	// The small max file size means each response is written to a new file.
+	recorder, err := NewRecordingSourceClient(source, c, dir, 1, 2)
+	if err != nil {
+		t.Fatalf("NewRecordingSourceClient() err got=%v, want=<nil>", err)
+	}
+	for i := 0; i < 3; i++ {
+		c.Add(time.Second)
+		recorder.GetStationToStopId(context.Background())
+	}
+	recorder.Close()
+
+	files, err := recordingFiles(dir)
+	if err != nil {
+		t.Fatalf("recordingFiles() err got=%v, want=<nil>", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("len(recordingFiles()) got=%d, want=2", len(files))
+	}
+	if _, err := os.Stat(otherFile); err != nil {
+		t.Errorf("os.Stat(%q) err got=%v, want=<nil>", otherFile, err)
+	}
+	replay, err := NewReplaySourceClient(clock.NewMock(), 1, dir)
+	if err != nil {
+		t.Fatalf("NewReplaySourceClient() err got=%v, want=<nil>", err)
+	}
+	if got, want := replay.recordingStart, time.Unix(0, 0).Add(2*time.Second); !got.Equal(want) {
+		t.Errorf("recording start got=%s, want=%s (the oldest file should have been deleted)", got, want)
+	}
+}
@@ -0,0 +1,258 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+type feedFormat int
+
+const (
+	formatProto feedFormat = iota
+	formatJSON
+	formatText
+	numFormats
+)
+
+var formatToContentType = map[feedFormat]string{
+	formatProto: "application/x-protobuf",
+	formatJSON:  "application/json",
+	formatText:  "text/plain; charset=utf-8",
+}
+
+// Values of the `format` query parameter.
+var queryParamToFormat = map[string]feedFormat{
+	"pb":   formatProto,
+	"json": formatJSON,
+	"text": formatText,
+}
+
+var formatToQueryParam = map[feedFormat]string{
+	formatProto: "pb",
+	formatJSON:  "json",
+	formatText:  "text",
+}
+
+// Media types in the Accept header.
+var mediaTypeToFormat = map[string]feedFormat{
+	"application/x-protobuf":   formatProto,
+	"application/protobuf":     formatProto,
+	"application/octet-stream": formatProto,
+	"application/json":         formatJSON,
+	"text/plain":               formatText,
+	"*/*":                      formatProto,
+}
+
+// A content coding of HTTP responses.
+type contentEncoding int
+
+const (
+	encodingIdentity contentEncoding = iota
+	encodingGzip
+	encodingDeflate
+	numEncodings
+)
+
+// The values of the Content-Encoding header.
+var encodingToName = map[contentEncoding]string{
+	encodingGzip:    "gzip",
+	encodingDeflate: "deflate",
+}
+
+// renderedFeed contains a feed message rendered in each of the supported formats, both uncompressed and
+// compressed with each supported encoding, along with the values of the cache validation headers.
+type renderedFeed struct {
+	bodies [numFormats][]byte
+	// Indexed by encoding; the identity encoding is not populated.
+	encoded [numEncodings][numFormats][]byte
+	// Identifies the version of the feed, and is used to build the ETag of each rendering.
+	version      string
+	lastModified time.Time
+}
+
+func renderFeed(msg *gtfs.FeedMessage) renderedFeed {
+	json, err := protojson.MarshalOptions{Multiline: true}.Marshal(msg)
+	if err != nil {
+		panic(fmt.Sprintf("failed to generate realtime JSON file: %s", err))
+	}
+	text, err := prototext.MarshalOptions{Multiline: true}.Marshal(msg)
+	if err != nil {
+		panic(fmt.Sprintf("failed to generate realtime text file: %s", err))
+	}
+	timestamp := msg.GetHeader().GetTimestamp()
+	r := renderedFeed{
+		bodies:       [numFormats][]byte{mustMarshal(msg), json, text},
+		version:      strconv.FormatUint(timestamp, 10),
+		lastModified: time.Unix(int64(timestamp), 0),
+	}
+	for format, body := range r.bodies {
+		r.encoded[encodingGzip][format] = mustGzip(body)
+		r.encoded[encodingDeflate][format] = mustDeflate(body)
+	}
+	return r
+}
+
+func (r renderedFeed) get(format feedFormat) []byte {
+	return r.bodies[format]
+}
+
+func (r renderedFeed) etag(format feedFormat, encoding contentEncoding) string {
+	etag := fmt.Sprintf("%s-%s", r.version, formatToQueryParam[format])
+	if encoding != encodingIdentity {
+		etag += "-" + encodingToName[encoding]
+	}
+	return `"` + etag + `"`
+}
+
+func mustGzip(b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		panic(fmt.Sprintf("failed to compress feed: %s", err))
+	}
+	if err := w.Close(); err != nil {
+		panic(fmt.Sprintf("failed to compress feed: %s", err))
+	}
+	return buf.Bytes()
+}
+
+// Compresses the data in the zlib format, which is what the deflate content coding is.
+func mustDeflate(b []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		panic(fmt.Sprintf("failed to compress feed: %s", err))
+	}
+	if err := w.Close(); err != nil {
+		panic(fmt.Sprintf("failed to compress feed: %s", err))
+	}
+	return buf.Bytes()
+}
+
+// Determines the format to respond with.
+//
+// The `format` query parameter takes precedence over the Accept header. If neither is provided, or the Accept
+// header contains no supported media types, the protobuf format is used.
+func negotiateFormat(r *http.Request) (feedFormat, error) {
+	if s := r.URL.Query().Get("format"); s != "" {
+		format, ok := queryParamToFormat[s]
+		if !ok {
+			return formatProto, fmt.Errorf("unknown format %q; supported formats are pb, json and text", s)
+		}
+		return format, nil
+	}
+	result := formatProto
+	bestQuality := -1.0
+	for _, value := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		format, ok := mediaTypeToFormat[mediaType]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality > 0 && quality > bestQuality {
+			result = format
+			bestQuality = quality
+		}
+	}
+	return result, nil
+}
+
+// Determines the content encoding to respond with from the Accept-Encoding header.
+//
+// The accepted encoding with the highest quality is used, preferring gzip over deflate if both are accepted
+// equally. A `*` accepts gzip. If no supported encoding is accepted, the response is not compressed.
+func negotiateEncoding(r *http.Request) contentEncoding {
+	quality := map[contentEncoding]float64{}
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch coding {
+		case "gzip":
+			quality[encodingGzip] = q
+		case "deflate":
+			quality[encodingDeflate] = q
+		case "*":
+			if _, ok := quality[encodingGzip]; !ok {
+				quality[encodingGzip] = q
+			}
+		}
+	}
+	result := encodingIdentity
+	bestQuality := 0.0
+	for _, encoding := range []contentEncoding{encodingGzip, encodingDeflate} {
+		if q := quality[encoding]; q > bestQuality {
+			result = encoding
+			bestQuality = q
+		}
+	}
+	return result
+}
+
+// A response writer that doesn't advertise support for range requests.
+type noRangesResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w noRangesResponseWriter) WriteHeader(code int) {
+	w.Header().Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Writes the rendering of the feed in the format requested by the client.
+//
+// The response is compressed if the client accepts gzip or deflate, and has the ETag and Last-Modified headers so that
+// clients and caches can make conditional requests, which are answered with 304 Not Modified if the feed has
+// not changed. Caches may reuse the response for the max age. Range requests are only supported for
+// uncompressed responses, since ranges of a compressed response would refer to the compressed bytes.
+func writeFeed(w http.ResponseWriter, r *http.Request, feed renderedFeed, maxAge time.Duration) {
+	format, err := negotiateFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	header := w.Header()
+	header.Set("Content-Type", formatToContentType[format])
+	header.Add("Vary", "Accept, Accept-Encoding")
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	body := feed.bodies[format]
+	encoding := negotiateEncoding(r)
+	if encoding != encodingIdentity {
+		body = feed.encoded[encoding][format]
+		header.Set("Content-Encoding", encodingToName[encoding])
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		w = noRangesResponseWriter{w}
+	}
+	header.Set("ETag", feed.etag(format, encoding))
+	http.ServeContent(w, r, "", feed.lastModified, bytes.NewReader(body))
+}
@@ -0,0 +1,283 @@
+package pathgtfsrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestWriteFeed(t *testing.T) {
+	msg := &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{
+			GtfsRealtimeVersion: ptr("0.2"),
+			Incrementality:      gtfsrt.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           ptr(uint64(makeTime(0).Unix())),
+		},
+		Entity: []*gtfsrt.FeedEntity{
+			{
+				Id: ptr("tripID"),
+				TripUpdate: &gtfsrt.TripUpdate{
+					Trip: &gtfsrt.TripDescriptor{TripId: ptr("tripID")},
+				},
+			},
+		},
+	}
+	rendered := renderFeed(msg)
+	unmarshalers := map[string]func([]byte, proto.Message) error{
+		"application/x-protobuf":    proto.Unmarshal,
+		"application/json":          protojson.Unmarshal,
+		"text/plain; charset=utf-8": prototext.Unmarshal,
+	}
+	for _, tc := range []struct {
+		name            string
+		url             string
+		accept          string
+		wantContentType string
+		wantStatus      int
+	}{
+		{
+			name:            "default",
+			url:             "/gtfsrt",
+			wantContentType: "application/x-protobuf",
+		},
+		{
+			name:            "query parameter json",
+			url:             "/gtfsrt?format=json",
+			wantContentType: "application/json",
+		},
+		{
+			name:            "query parameter text",
+			url:             "/gtfsrt?format=text",
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:            "query parameter takes precedence",
+			url:             "/gtfsrt?format=pb",
+			accept:          "application/json",
+			wantContentType: "application/x-protobuf",
+		},
+		{
+			name:       "unknown query parameter",
+			url:        "/gtfsrt?format=xml",
+			wantStatus: 400,
+		},
+		{
+			name:            "accept json",
+			url:             "/gtfsrt",
+			accept:          "application/json",
+			wantContentType: "application/json",
+		},
+		{
+			name:            "accept with quality",
+			url:             "/gtfsrt",
+			accept:          "application/json;q=0.5, text/plain, */*;q=0.1",
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:            "accept unsupported",
+			url:             "/gtfsrt",
+			accept:          "text/html",
+			wantContentType: "application/x-protobuf",
+		},
+		{
+			name:            "accept browser default",
+			url:             "/gtfsrt",
+			accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			wantContentType: "application/x-protobuf",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.url, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+
+			writeFeed(w, r, rendered, 5*time.Second)
+
+			wantStatus := tc.wantStatus
+			if wantStatus == 0 {
+				wantStatus = 200
+			}
+			if w.Code != wantStatus {
+				t.Fatalf("status code got=%d, want=%d", w.Code, wantStatus)
+			}
+			if wantStatus != 200 {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tc.wantContentType {
+				t.Errorf("Content-Type got=%q, want=%q", got, tc.wantContentType)
+			}
+			gotMsg := &gtfsrt.FeedMessage{}
+			if err := unmarshalers[tc.wantContentType](w.Body.Bytes(), gotMsg); err != nil {
+				t.Fatalf("unmarshal err got=%v, want=<nil>", err)
+			}
+			if diff := cmp.Diff(msg, gotMsg, protocmp.Transform()); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteFeed_Caching(t *testing.T) {
+	msg := &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{
+			GtfsRealtimeVersion: ptr("0.2"),
+			Timestamp:           ptr(uint64(makeTime(0).Unix())),
+		},
+	}
+	rendered := renderFeed(msg)
+	lastModified := makeTime(0).Format(http.TimeFormat)
+	for _, tc := range []struct {
+		name         string
+		headers      map[string]string
+		wantStatus   int
+		wantETag     string
+		wantFormat   feedFormat
+		wantEncoding string
+	}{
+		{
+			name:       "unconditional",
+			wantStatus: 200,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:         "gzip",
+			headers:      map[string]string{"Accept-Encoding": "gzip, deflate, br"},
+			wantStatus:   200,
+			wantETag:     `"1677405600-pb-gzip"`,
+			wantEncoding: "gzip",
+		},
+		{
+			name:         "deflate",
+			headers:      map[string]string{"Accept-Encoding": "deflate"},
+			wantStatus:   200,
+			wantETag:     `"1677405600-pb-deflate"`,
+			wantEncoding: "deflate",
+		},
+		{
+			name:         "deflate preferred",
+			headers:      map[string]string{"Accept-Encoding": "gzip;q=0.5, deflate"},
+			wantStatus:   200,
+			wantETag:     `"1677405600-pb-deflate"`,
+			wantEncoding: "deflate",
+		},
+		{
+			name:       "range of uncompressed response",
+			headers:    map[string]string{"Range": "bytes=0-9"},
+			wantStatus: 206,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:         "range of compressed response",
+			headers:      map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"},
+			wantStatus:   200,
+			wantETag:     `"1677405600-pb-gzip"`,
+			wantEncoding: "gzip",
+		},
+		{
+			name:       "gzip not acceptable",
+			headers:    map[string]string{"Accept-Encoding": "gzip;q=0, br"},
+			wantStatus: 200,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:       "matching ETag",
+			headers:    map[string]string{"If-None-Match": `"1677405600-pb"`},
+			wantStatus: 304,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:       "ETag of different format",
+			headers:    map[string]string{"If-None-Match": `"1677405600-pb"`, "Accept": "application/json"},
+			wantStatus: 200,
+			wantETag:   `"1677405600-json"`,
+			wantFormat: formatJSON,
+		},
+		{
+			name:       "old ETag",
+			headers:    map[string]string{"If-None-Match": `"1677405595-pb"`},
+			wantStatus: 200,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:       "not modified since",
+			headers:    map[string]string{"If-Modified-Since": lastModified},
+			wantStatus: 304,
+			wantETag:   `"1677405600-pb"`,
+		},
+		{
+			name:       "modified since",
+			headers:    map[string]string{"If-Modified-Since": makeTime(-1).Format(http.TimeFormat)},
+			wantStatus: 200,
+			wantETag:   `"1677405600-pb"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/gtfsrt", nil)
+			for key, value := range tc.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			writeFeed(w, r, rendered, 5*time.Second)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status code got=%d, want=%d", w.Code, tc.wantStatus)
+			}
+			wantHeaders := map[string]string{
+				"ETag":          tc.wantETag,
+				"Cache-Control": "public, max-age=5",
+			}
+			if tc.wantStatus == 200 {
+				wantHeaders["Last-Modified"] = lastModified
+			}
+			for key, want := range wantHeaders {
+				if got := w.Header().Get(key); got != want {
+					t.Errorf("%s header got=%q, want=%q", key, got, want)
+				}
+			}
+			if tc.wantStatus != 200 {
+				return
+			}
+			body := w.Body.Bytes()
+			if got := w.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Content-Encoding header got=%q, want=%q", got, tc.wantEncoding)
+			}
+			if tc.wantEncoding != "" {
+				if got := w.Header().Get("Accept-Ranges"); got != "" {
+					t.Errorf("Accept-Ranges header got=%q, want no header", got)
+				}
+				var reader io.Reader
+				var err error
+				if tc.wantEncoding == "gzip" {
+					reader, err = gzip.NewReader(bytes.NewReader(body))
+				} else {
+					reader, err = zlib.NewReader(bytes.NewReader(body))
+				}
+				if err != nil {
+					t.Fatalf("NewReader() err got=%v, want=<nil>", err)
+				}
+				body, err = io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("io.ReadAll() err got=%v, want=<nil>", err)
+				}
+			}
+			if !bytes.Equal(body, rendered.get(tc.wantFormat)) {
+				t.Errorf("response body does not match the rendered feed")
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResilienceConfig configures a ResilientSourceClient. The zero value disables all of its features.
+type ResilienceConfig struct {
+	// The maximum number of attempts of each request, including the first. Zero or one means requests are not retried.
+	MaxAttempts int
+	// The maximum backoff before the first retry. This doubles for each subsequent retry, up to the max backoff.
+	// The actual backoff is chosen at random up to the maximum.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// The total time each request may take including retries and waiting for the rate limiter, typically the
+	// feed's update period. Retries that wouldn't start within the budget aren't attempted. Zero means no limit.
+	Budget time.Duration
+	// The number of consecutive failed requests to an endpoint after which its circuit breaker opens.
+	// Zero disables the circuit breakers.
+	BreakerThreshold int
+	// How long a circuit breaker stays open before a trial request is allowed through.
+	BreakerCooldown time.Duration
+	// The maximum number of requests per second, including retries. Zero means no limit.
+	RateLimit float64
+	// The number of requests that can be made in a burst above the rate limit.
+	RateLimitBurst int
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// Requests are allowed.
+	BreakerClosed BreakerState = iota
+	// A single trial request is allowed to check whether the endpoint has recovered.
+	BreakerHalfOpen
+	// Requests are rejected without being sent.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// ResilienceStats are counts of the actions taken by a ResilientSourceClient since it was created.
+type ResilienceStats struct {
+	// The number of retried requests.
+	Retries uint64
+	// The number of requests that had to wait for the rate limiter, or failed because the wait would exceed
+	// the budget.
+	RateLimited uint64
+	// The number of requests rejected because a circuit breaker was open.
+	Rejected uint64
+}
+
+// ErrCircuitOpen is returned for requests rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrRateLimited is returned for requests that can't be made within the budget because of the rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ResilientSourceClient is a source client that makes requests to another source client more resilient to
+// transient failures, and protects the source API from too many requests.
+//
+// Failed requests are retried with jittered exponential backoff, within the budget. Requests failing with gRPC
+// codes that indicate the request is invalid are not retried.
+//
+// Each endpoint has a circuit breaker. The endpoints are the stations and routes requests, and the trains
+// request for each station, unless the wrapped client serves several of them from a single source API endpoint,
+// like the PANYNJ client does for the trains of all stations. After the threshold number of consecutive failures
+// the breaker opens, and requests to the endpoint fail immediately. After the cooldown a single trial request is
+// allowed through; if it succeeds the breaker closes, and otherwise it opens again.
+//
+// All requests sent to the source API, including retries, are subject to the rate limit. Requests the wrapped client
+// answers from its cache are passed straight through, and are not retried.
+type ResilientSourceClient struct {
+	sourceClient SourceClient
+	clock        clock.Clock
+	config       ResilienceConfig
+
+	// Held while making requests to a wrapped client with shared upstream endpoints, so that only one request
+	// is sent to each endpoint and the others are answered from the client's cache.
+	upstreamMutex sync.Mutex
+
+	mutex    sync.Mutex
+	rand     *rand.Rand
+	breakers map[string]*circuitBreaker
+	// The rate limiter's token bucket.
+	tokens     float64
+	lastRefill time.Time
+	stats      ResilienceStats
+}
+
+type circuitBreaker struct {
+	state           BreakerState
+	consecutiveErrs int
+	openedAt        time.Time
+}
+
+func NewResilientSourceClient(sourceClient SourceClient, clock clock.Clock, config ResilienceConfig) *ResilientSourceClient {
+	return &ResilientSourceClient{
+		sourceClient: sourceClient,
+		clock:        clock,
+		config:       config,
+		rand:         rand.New(rand.NewSource(clock.Now().UnixNano())),
+		breakers:     map[string]*circuitBreaker{},
+		tokens:       float64(config.RateLimitBurst),
+		lastRefill:   clock.Now(),
+	}
+}
+
+// Close closes the wrapped source client, if it can be closed.
+func (c *ResilientSourceClient) Close() error {
+	if closer, ok := c.sourceClient.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// BreakerStates returns the state of the circuit breaker of each endpoint that has been requested.
+func (c *ResilientSourceClient) BreakerStates() map[string]BreakerState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	states := map[string]BreakerState{}
+	for endpoint, breaker := range c.breakers {
+		states[endpoint] = breaker.state
+	}
+	return states
+}
+
+// Stats returns the counts of the actions taken by the client.
+func (c *ResilientSourceClient) Stats() ResilienceStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+func (c *ResilientSourceClient) GetStationToStopId(ctx context.Context) (map[sourceapi.Station]string, error) {
+	return resilientRequest(ctx, c, "stations", func() (map[sourceapi.Station]string, error) {
+		return c.sourceClient.GetStationToStopId(ctx)
+	})
+}
+
+func (c *ResilientSourceClient) GetRouteToRouteId(ctx context.Context) (map[sourceapi.Route]string, error) {
+	return resilientRequest(ctx, c, "routes", func() (map[sourceapi.Route]string, error) {
+		return c.sourceClient.GetRouteToRouteId(ctx)
+	})
+}
+
+func (c *ResilientSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	return resilientRequest(ctx, c, trainsEndpointPrefix+station.String(), func() ([]Train, error) {
+		return c.sourceClient.GetTrainsAtStation(ctx, station)
+	})
+}
+
+// The prefix of the endpoints of trains requests, which is followed by the station.
+const trainsEndpointPrefix = "trains/"
+
+// upstreamSourceClient is implemented by source clients whose requests don't correspond one to one to requests
+// to the source API, so that a ResilientSourceClient protects the source API endpoints themselves.
+type upstreamSourceClient interface {
+	// Returns the source API endpoint that serves requests to the endpoint, and whether the next request will be
+	// sent to it rather than answered from a cache.
+	upstreamEndpoint(endpoint string) (upstream string, sent bool)
+}
+
+// The result of a single attempt of a request.
+type attemptResult int
+
+const (
+	// The request was sent to the wrapped client.
+	attemptSent attemptResult = iota
+	// The request was answered from the wrapped client's cache, so retrying it would give the same result.
+	attemptCached
+	// The request wasn't made because of the rate limiter or a circuit breaker.
+	attemptRejected
+)
+
+func resilientRequest[T any](ctx context.Context, c *ResilientSourceClient, endpoint string, request func() (T, error)) (T, error) {
+	var zero T
+	var deadline time.Time
+	if c.config.Budget > 0 {
+		deadline = c.clock.Now().Add(c.config.Budget)
+	}
+	var lastErr error
+	for attempt := 0; attempt == 0 || attempt < c.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			// A retry answered from the wrapped client's cache would fail in the same way.
+			if !c.sendsUpstream(endpoint) {
+				break
+			}
+			if err := c.wait(ctx, c.backoff(attempt), deadline); err != nil {
+				break
+			}
+			c.mutex.Lock()
+			c.stats.Retries++
+			c.mutex.Unlock()
+		}
+		result, err, outcome := attemptRequest(ctx, c, endpoint, deadline, request)
+		if err == nil {
+			return result, nil
+		}
+		if outcome == attemptRejected {
+			if lastErr == nil {
+				lastErr = err
+			}
+			break
+		}
+		lastErr = err
+		if outcome == attemptCached || !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return zero, lastErr
+}
+
+// Returns whether a request to the endpoint would be sent to the source API.
+func (c *ResilientSourceClient) sendsUpstream(endpoint string) bool {
+	client, ok := c.sourceClient.(upstreamSourceClient)
+	if !ok {
+		return true
+	}
+	_, sent := client.upstreamEndpoint(endpoint)
+	return sent
+}
+
+// Makes a single attempt of the request, subject to the rate limiter and the circuit breaker of the source API
+// endpoint that serves it.
+func attemptRequest[T any](ctx context.Context, c *ResilientSourceClient, endpoint string, deadline time.Time, request func() (T, error)) (T, error, attemptResult) {
+	var zero T
+	upstream := endpoint
+	if client, ok := c.sourceClient.(upstreamSourceClient); ok {
+		c.upstreamMutex.Lock()
+		defer c.upstreamMutex.Unlock()
+		var sent bool
+		upstream, sent = client.upstreamEndpoint(endpoint)
+		if !sent {
+			result, err := request()
+			return result, err, attemptCached
+		}
+	}
+	if err := c.waitForRateLimit(ctx, deadline); err != nil {
+		return zero, err, attemptRejected
+	}
+	if err := c.allow(upstream); err != nil {
+		return zero, err, attemptRejected
+	}
+	result, err := request()
+	c.record(upstream, err)
+	return result, err, attemptSent
+}
+
+// Returns whether a failed request may succeed if retried.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.Unauthenticated,
+			codes.Unimplemented, codes.FailedPrecondition:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the backoff before the retry, using full jitter.
+func (c *ResilientSourceClient) backoff(attempt int) time.Duration {
+	maxBackoff := c.config.InitialBackoff
+	for i := 1; i < attempt && (c.config.MaxBackoff <= 0 || maxBackoff < c.config.MaxBackoff); i++ {
+		maxBackoff *= 2
+	}
+	if c.config.MaxBackoff > 0 && maxBackoff > c.config.MaxBackoff {
+		maxBackoff = c.config.MaxBackoff
+	}
+	if maxBackoff <= 0 {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return time.Duration(c.rand.Int63n(int64(maxBackoff) + 1))
+}
+
+// Waits for the duration, unless waiting would end after the deadline or the context is done first.
+func (c *ResilientSourceClient) wait(ctx context.Context, d time.Duration, deadline time.Time) error {
+	if !deadline.IsZero() && c.clock.Now().Add(d).After(deadline) {
+		return fmt.Errorf("waiting %s would exceed the budget", d)
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := c.clock.Timer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Takes a token from the rate limiter's bucket, waiting for one to become available if necessary.
+func (c *ResilientSourceClient) waitForRateLimit(ctx context.Context, deadline time.Time) error {
+	if c.config.RateLimit <= 0 {
+		return nil
+	}
+	c.mutex.Lock()
+	now := c.clock.Now()
+	c.tokens += now.Sub(c.lastRefill).Seconds() * c.config.RateLimit
+	if burst := float64(c.config.RateLimitBurst); c.tokens > burst {
+		c.tokens = burst
+	}
+	c.lastRefill = now
+	if c.tokens >= 1 {
+		c.tokens--
+		c.mutex.Unlock()
+		return nil
+	}
+	c.stats.RateLimited++
+	d := time.Duration((1 - c.tokens) / c.config.RateLimit * float64(time.Second))
+	if !deadline.IsZero() && now.Add(d).After(deadline) {
+		c.mutex.Unlock()
+		return ErrRateLimited
+	}
+	// The token is reserved now so that concurrent requests wait in turn.
+	c.tokens--
+	c.mutex.Unlock()
+	return c.wait(ctx, d, time.Time{})
+}
+
+// Returns an error if the endpoint's circuit breaker doesn't allow a request.
+func (c *ResilientSourceClient) allow(endpoint string) error {
+	if c.config.BreakerThreshold <= 0 {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	breaker := c.breaker(endpoint)
+	switch breaker.state {
+	case BreakerOpen:
+		if c.clock.Since(breaker.openedAt) >= c.config.BreakerCooldown {
+			breaker.state = BreakerHalfOpen
+			return nil
+		}
+	case BreakerHalfOpen:
+		// A trial request is in progress.
+	default:
+		return nil
+	}
+	c.stats.Rejected++
+	return fmt.Errorf("%s: %w", endpoint, ErrCircuitOpen)
+}
+
+func (c *ResilientSourceClient) record(endpoint string, err error) {
+	if c.config.BreakerThreshold <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	breaker := c.breaker(endpoint)
+	if err == nil {
+		if breaker.state != BreakerClosed {
+			fmt.Printf("Circuit breaker for %s closed\n", endpoint)
+		}
+		breaker.state = BreakerClosed
+		breaker.consecutiveErrs = 0
+		return
+	}
+	breaker.consecutiveErrs++
+	if breaker.state == BreakerHalfOpen || (breaker.state == BreakerClosed && breaker.consecutiveErrs >= c.config.BreakerThreshold) {
+		fmt.Printf("Circuit breaker for %s opened after %d consecutive failures\n", endpoint, breaker.consecutiveErrs)
+		breaker.state = BreakerOpen
+		breaker.openedAt = c.clock.Now()
+	}
+}
+
+func (c *ResilientSourceClient) breaker(endpoint string) *circuitBreaker {
+	breaker, ok := c.breakers[endpoint]
+	if !ok {
+		breaker = &circuitBreaker{}
+		c.breakers[endpoint] = breaker
+	}
+	return breaker
+}
@@ -0,0 +1,274 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A source client whose train requests fail a number of times before succeeding.
+type failingSourceClient struct {
+	mockSourceClient
+	failures int
+	err      error
+	requests int
+}
+
+func (f *failingSourceClient) GetTrainsAtStation(ctx context.Context, station sourceapi.Station) ([]Train, error) {
+	f.requests++
+	if f.failures != 0 {
+		f.failures--
+		return nil, f.err
+	}
+	return f.mockSourceClient.GetTrainsAtStation(ctx, station)
+}
+
+func newFailingSourceClient(failures int, err error) *failingSourceClient {
+	return &failingSourceClient{
+		mockSourceClient: mockSourceClient{
+			stationToTrains: map[sourceapi.Station][]Train{
+				sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+			},
+		},
+		failures: failures,
+		err:      err,
+	}
+}
+
+func TestResilientSourceClient_Retries(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		failures     int
+		err          error
+		maxAttempts  int
+		wantErr      bool
+		wantRequests int
+		wantRetries  uint64
+	}{
+		{
+			name:         "no retries",
+			failures:     1,
+			err:          errors.New("failed"),
+			maxAttempts:  1,
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name:         "succeeds after retries",
+			failures:     2,
+			err:          errors.New("failed"),
+			maxAttempts:  3,
+			wantRequests: 3,
+			wantRetries:  2,
+		},
+		{
+			name:         "fails after max attempts",
+			failures:     3,
+			err:          errors.New("failed"),
+			maxAttempts:  2,
+			wantErr:      true,
+			wantRequests: 2,
+			wantRetries:  1,
+		},
+		{
+			name:         "transient gRPC error is retried",
+			failures:     1,
+			err:          status.Error(codes.Unavailable, "unavailable"),
+			maxAttempts:  2,
+			wantRequests: 2,
+			wantRetries:  1,
+		},
+		{
+			name:         "invalid request is not retried",
+			failures:     1,
+			err:          status.Error(codes.NotFound, "not found"),
+			maxAttempts:  2,
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newFailingSourceClient(tc.failures, tc.err)
+			client := NewResilientSourceClient(source, clock.NewMock(), ResilienceConfig{MaxAttempts: tc.maxAttempts})
+
+			_, err := client.GetTrainsAtStation(context.Background(), sourceapi.Station_HOBOKEN)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("GetTrainsAtStation() err got=%v, wantErr=%t", err, tc.wantErr)
+			}
+			if source.requests != tc.wantRequests {
+				t.Errorf("number of requests got=%d, want=%d", source.requests, tc.wantRequests)
+			}
+			if got := client.Stats().Retries; got != tc.wantRetries {
+				t.Errorf("Stats().Retries got=%d, want=%d", got, tc.wantRetries)
+			}
+		})
+	}
+}
+
+func TestResilientSourceClient_Backoff(t *testing.T) {
+	client := NewResilientSourceClient(&mockSourceClient{}, clock.NewMock(), ResilienceConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	})
+	for attempt, wantMax := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if got := client.backoff(attempt); got < 0 || got > wantMax {
+				t.Errorf("backoff(%d) got=%s, want between 0s and %s", attempt, got, wantMax)
+			}
+		}
+	}
+}
+
+func TestResilientSourceClient_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	source := &flakySourceClient{
+		mockSourceClient: mockSourceClient{
+			stationToTrains: map[sourceapi.Station][]Train{
+				sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+			},
+		},
+		down: true,
+	}
+	c := clock.NewMock()
+	client := NewResilientSourceClient(source, c, ResilienceConfig{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	request := func() error {
+		_, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+		return err
+	}
+	checkState := func(want BreakerState) {
+		t.Helper()
+		if diff := cmp.Diff(map[string]BreakerState{"trains/HOBOKEN": want}, client.BreakerStates()); diff != "" {
+			t.Errorf("BreakerStates() mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	request()
+	checkState(BreakerClosed)
+	request()
+	checkState(BreakerOpen)
+	if err := request(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("GetTrainsAtStation() err got=%v, want=%v", err, ErrCircuitOpen)
+	}
+	if source.requests != 2 {
+		t.Errorf("number of requests got=%d, want=2", source.requests)
+	}
+
+	// The trial request after the cooldown fails, so the breaker opens again.
+	c.Add(time.Minute)
+	if err := request(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("GetTrainsAtStation() err got=%v, want the source's error", err)
+	}
+	checkState(BreakerOpen)
+
+	// The trial request after the next cooldown succeeds, so the breaker closes.
+	source.down = false
+	c.Add(time.Minute)
+	if err := request(); err != nil {
+		t.Errorf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	checkState(BreakerClosed)
+	if got := client.Stats().Rejected; got != 1 {
+		t.Errorf("Stats().Rejected got=%d, want=1", got)
+	}
+}
+
+func TestResilientSourceClient_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	source := newFailingSourceClient(0, nil)
+	c := clock.NewMock()
+	client := NewResilientSourceClient(source, c, ResilienceConfig{
+		RateLimit:      1,
+		RateLimitBurst: 2,
+		Budget:         500 * time.Millisecond,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN); err != nil {
+			t.Errorf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+		}
+	}
+	// The burst is used up, and the next token isn't available within the budget.
+	if _, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("GetTrainsAtStation() err got=%v, want=%v", err, ErrRateLimited)
+	}
+	c.Add(time.Second)
+	if _, err := client.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN); err != nil {
+		t.Errorf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	if source.requests != 3 {
+		t.Errorf("number of requests got=%d, want=3", source.requests)
+	}
+	if got := client.Stats().RateLimited; got != 1 {
+		t.Errorf("Stats().RateLimited got=%d, want=1", got)
+	}
+}
+
+// An HTTP client whose requests all fail with a 503 status.
+type unavailableHttpClient struct {
+	requests int
+}
+
+func (c *unavailableHttpClient) Get(string) (*http.Response, error) {
+	c.requests++
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "503 Service Unavailable",
+		Body:       io.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func TestResilientSourceClient_SharedUpstreamEndpoint(t *testing.T) {
+	ctx := context.Background()
+	httpClient := &unavailableHttpClient{}
+	c := clock.NewMock()
+	client := NewResilientSourceClient(NewPaNyNjSourceClient(httpClient, c), c, ResilienceConfig{
+		MaxAttempts:      3,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+	requestAllStations := func() {
+		for station := range sourceapi.Station_name {
+			if station == 0 {
+				continue
+			}
+			if _, err := client.GetTrainsAtStation(ctx, sourceapi.Station(station)); err == nil {
+				t.Errorf("GetTrainsAtStation(%s) err got=<nil>, want the source's error", sourceapi.Station(station))
+			}
+		}
+	}
+	checkState := func(want BreakerState) {
+		t.Helper()
+		if diff := cmp.Diff(map[string]BreakerState{"trains": want}, client.BreakerStates()); diff != "" {
+			t.Errorf("BreakerStates() mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	// The trains of all stations are served by a single request, whose error is cached, so it is neither
+	// retried nor counted as a failure more than once.
+	requestAllStations()
+	if httpClient.requests != 1 {
+		t.Errorf("number of requests got=%d, want=1", httpClient.requests)
+	}
+	checkState(BreakerClosed)
+	if got := client.Stats().Retries; got != 0 {
+		t.Errorf("Stats().Retries got=%d, want=0", got)
+	}
+
+	c.Add(cacheValidityTime)
+	requestAllStations()
+	if httpClient.requests != 2 {
+		t.Errorf("number of requests got=%d, want=2", httpClient.requests)
+	}
+	checkState(BreakerOpen)
+}
@@ -0,0 +1,181 @@
+package pathgtfsrt
+
+import (
+	"sort"
+	"time"
+
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// The maximum difference between a train's predicted arrival at a station and a scheduled trip's
+// arrival at the station for the train to be matched to the scheduled trip.
+const maxScheduleDeviation = 15 * time.Minute
+
+// The schedule of a realtime trip, as determined by a ScheduleMatcher.
+type tripSchedule struct {
+	relationship gtfs.TripDescriptor_ScheduleRelationship
+	// The following fields are only set for scheduled trips.
+	tripId string
+	// The service day of the scheduled trip, in YYYYMMDD format.
+	startDate        string
+	stationToArrival map[sourceapi.Station]time.Time
+}
+
+// Returns the difference between the predicted and scheduled arrival at the station, if the station is scheduled.
+func (s *tripSchedule) delay(stop tripStop) (time.Duration, bool) {
+	if s == nil {
+		return 0, false
+	}
+	scheduled, ok := s.stationToArrival[stop.station]
+	if !ok {
+		return 0, false
+	}
+	return stop.train.ProjectedArrival.AsTime().Sub(scheduled), true
+}
+
+type scheduleKey struct {
+	route     sourceapi.Route
+	direction sourceapi.Direction
+}
+
+type scheduledTrip struct {
+	id        string
+	serviceId string
+	// Map from station to the scheduled arrival, in seconds after the start of the service day.
+	stationToArrival map[sourceapi.Station]int
+}
+
+// ScheduleMatcher matches realtime trips to the scheduled trips in a GTFS static feed.
+//
+// A realtime trip matches a scheduled trip if they have the same route and direction, the scheduled
+// trip runs on the service day of the realtime trip, and the predicted arrival at the first station
+// of the realtime trip is close to the scheduled arrival. Each scheduled trip is matched to at most
+// one realtime trip, with the closest matches taking priority.
+type ScheduleMatcher struct {
+	gtfsStatic *GtfsStatic
+	trips      map[scheduleKey][]scheduledTrip
+}
+
+func NewScheduleMatcher(gtfsStatic *GtfsStatic) *ScheduleMatcher {
+	stopIdToStation := map[string]sourceapi.Station{}
+	for station, stopId := range gtfsStatic.stationToStopId {
+		stopIdToStation[stopId] = station
+	}
+	for _, stop := range gtfsStatic.stops {
+		if station, ok := stopIdToStation[stop.parentStation]; ok {
+			stopIdToStation[stop.id] = station
+		}
+	}
+	routeIdToRoute := map[string]sourceapi.Route{}
+	for route, routeId := range gtfsStatic.routeToRouteId {
+		routeIdToRoute[routeId] = route
+	}
+	matcher := &ScheduleMatcher{
+		gtfsStatic: gtfsStatic,
+		trips:      map[scheduleKey][]scheduledTrip{},
+	}
+	for tripId, trip := range gtfsStatic.trips {
+		route, ok := routeIdToRoute[trip.routeId]
+		if !ok {
+			continue
+		}
+		direction, ok := gtfsStatic.direction(trip.directionId)
+		if !ok {
+			continue
+		}
+		scheduled := scheduledTrip{
+			id:               tripId,
+			serviceId:        trip.serviceId,
+			stationToArrival: map[sourceapi.Station]int{},
+		}
+		for _, stopTime := range gtfsStatic.stopTimes[tripId] {
+			if station, ok := stopIdToStation[stopTime.stopId]; ok && stopTime.hasArrivalTime {
+				scheduled.stationToArrival[station] = stopTime.arrivalTime
+			}
+		}
+		key := scheduleKey{route: route, direction: direction}
+		matcher.trips[key] = append(matcher.trips[key], scheduled)
+	}
+	for _, trips := range matcher.trips {
+		sort.Slice(trips, func(i, j int) bool {
+			return trips[i].id < trips[j].id
+		})
+	}
+	return matcher
+}
+
+// Sets the schedule of each of the trips.
+//
+// Trips that match a scheduled trip are SCHEDULED. Trips that don't match are ADDED if their route runs
+// on the service day, and otherwise UNSCHEDULED.
+func (matcher *ScheduleMatcher) match(trips []*trip) {
+	type candidate struct {
+		current   *trip
+		scheduled scheduledTrip
+		date      time.Time
+		diff      time.Duration
+	}
+	var candidates []candidate
+	hasService := map[*trip]bool{}
+	for _, current := range trips {
+		current.schedule = nil
+		first := current.stops[0]
+		arrival := first.train.ProjectedArrival.AsTime().In(matcher.gtfsStatic.location)
+		today := time.Date(arrival.Year(), arrival.Month(), arrival.Day(), 0, 0, 0, 0, matcher.gtfsStatic.location)
+		// Trips running past midnight belong to the previous service day.
+		for _, date := range []time.Time{today, today.AddDate(0, 0, -1)} {
+			for _, scheduled := range matcher.trips[scheduleKey{route: current.route, direction: current.direction}] {
+				if !matcher.gtfsStatic.isServiceActive(scheduled.serviceId, date) {
+					continue
+				}
+				hasService[current] = true
+				seconds, ok := scheduled.stationToArrival[first.station]
+				if !ok {
+					continue
+				}
+				diff := arrival.Sub(matcher.gtfsStatic.serviceDayTime(date, seconds))
+				if diff < 0 {
+					diff = -diff
+				}
+				if diff > maxScheduleDeviation {
+					continue
+				}
+				candidates = append(candidates, candidate{current: current, scheduled: scheduled, date: date, diff: diff})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].diff < candidates[j].diff
+	})
+	// Each scheduled trip is matched at most once, even across service days, so that no two trips in the feed
+	// have the same trip ID.
+	matchedScheduled := map[string]bool{}
+	for _, c := range candidates {
+		startDate := c.date.Format("20060102")
+		if c.current.schedule != nil || matchedScheduled[c.scheduled.id] {
+			continue
+		}
+		matchedScheduled[c.scheduled.id] = true
+		schedule := &tripSchedule{
+			relationship:     gtfs.TripDescriptor_SCHEDULED,
+			tripId:           c.scheduled.id,
+			startDate:        startDate,
+			stationToArrival: map[sourceapi.Station]time.Time{},
+		}
+		for station, seconds := range c.scheduled.stationToArrival {
+			schedule.stationToArrival[station] = matcher.gtfsStatic.serviceDayTime(c.date, seconds)
+		}
+		c.current.schedule = schedule
+	}
+	for _, current := range trips {
+		if current.schedule != nil {
+			continue
+		}
+		if hasService[current] {
+			current.schedule = &tripSchedule{relationship: gtfs.TripDescriptor_ADDED}
+		} else {
+			current.schedule = &tripSchedule{relationship: gtfs.TripDescriptor_UNSCHEDULED}
+		}
+	}
+}
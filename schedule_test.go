@@ -0,0 +1,198 @@
+package pathgtfsrt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestScheduleMatcher(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	// A Monday.
+	weekday := func(hour, minute int) time.Time {
+		return time.Date(2023, time.December, 18, hour, minute, 0, 0, est)
+	}
+	// Christmas day, a Monday with a weekend schedule.
+	holiday := func(hour, minute int) time.Time {
+		return time.Date(2023, time.December, 25, hour, minute, 0, 0, est)
+	}
+	type wantSchedule struct {
+		relationship gtfsrt.TripDescriptor_ScheduleRelationship
+		tripId       string
+		startDate    string
+		delay        time.Duration
+	}
+	for _, tc := range []struct {
+		name          string
+		trips         []*trip
+		wantSchedules []wantSchedule
+	}{
+		{
+			name: "late train",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(6, 2), sourceapi.Station_HOBOKEN, sourceapi.Station_CHRISTOPHER_STREET),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKDY_1_0600", startDate: "20231218", delay: 2 * time.Minute},
+			},
+		},
+		{
+			name: "early train",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(6, 13), sourceapi.Station_HOBOKEN),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKDY_1_0615", startDate: "20231218", delay: -2 * time.Minute},
+			},
+		},
+		{
+			name: "holiday schedule",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, holiday(6, 17), sourceapi.Station_HOBOKEN),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKND_1_0630", startDate: "20231225", delay: -13 * time.Minute},
+			},
+		},
+		{
+			name: "two trains close to the same scheduled trip",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(6, 4), sourceapi.Station_HOBOKEN),
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(6, 1), sourceapi.Station_HOBOKEN),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKDY_1_0615", startDate: "20231218", delay: -11 * time.Minute},
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKDY_1_0600", startDate: "20231218", delay: 1 * time.Minute},
+			},
+		},
+		{
+			name: "later station",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(6, 4), sourceapi.Station_CHRISTOPHER_STREET),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_SCHEDULED, tripId: "859_WKDY_1_0600", startDate: "20231218", delay: 1 * time.Minute},
+			},
+		},
+		{
+			name: "no scheduled trip close to the train",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, weekday(10, 0), sourceapi.Station_HOBOKEN),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_ADDED},
+			},
+		},
+		{
+			name: "route not in schedule",
+			trips: []*trip{
+				scheduleTestTrip(sourceapi.Route_NPT_HOB, sourceapi.Direction_TO_NJ, weekday(6, 0), sourceapi.Station_NEWPORT),
+			},
+			wantSchedules: []wantSchedule{
+				{relationship: gtfsrt.TripDescriptor_UNSCHEDULED},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher := NewScheduleMatcher(loadTestGtfsStatic(t))
+
+			matcher.match(tc.trips)
+
+			var gotSchedules []wantSchedule
+			for _, tr := range tc.trips {
+				got := wantSchedule{
+					relationship: tr.schedule.relationship,
+					tripId:       tr.schedule.tripId,
+					startDate:    tr.schedule.startDate,
+				}
+				got.delay, _ = tr.schedule.delay(tr.stops[0])
+				gotSchedules = append(gotSchedules, got)
+			}
+			if diff := cmp.Diff(tc.wantSchedules, gotSchedules, cmp.AllowUnexported(wantSchedule{})); diff != "" {
+				t.Errorf("match() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildGtfsRealtimeFeedMessage_Scheduled(t *testing.T) {
+	staticData := staticData{
+		stationToStopId: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: stopIDHoboken,
+		},
+		routeToRouteId: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+	}
+	tr := &trip{
+		id:        "tripID",
+		route:     sourceapi.Route_HOB_33,
+		direction: sourceapi.Direction_TO_NY,
+		stops: []tripStop{
+			{station: sourceapi.Station_HOBOKEN, train: sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 12, 1)},
+		},
+		schedule: &tripSchedule{
+			relationship: gtfsrt.TripDescriptor_SCHEDULED,
+			tripId:       "scheduledTripID",
+			startDate:    "20230226",
+			stationToArrival: map[sourceapi.Station]time.Time{
+				sourceapi.Station_HOBOKEN: makeTime(10),
+			},
+		},
+	}
+
+	msg := buildGtfsRealtimeFeedMessage(clock.NewMock(), staticData, []*trip{tr})
+
+	wantEntities := []*gtfsrt.FeedEntity{
+		{
+			// The entity ID is the tracked trip ID, which doesn't change when the trip is matched.
+			Id: ptr("tripID"),
+			TripUpdate: &gtfsrt.TripUpdate{
+				Trip: &gtfsrt.TripDescriptor{
+					TripId:               ptr("scheduledTripID"),
+					RouteId:              ptr(routeID1),
+					DirectionId:          ptr(uint32(1)),
+					StartDate:            ptr("20230226"),
+					ScheduleRelationship: gtfsrt.TripDescriptor_SCHEDULED.Enum(),
+				},
+				StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{
+					{
+						StopId: ptr(stopIDHoboken),
+						Arrival: &gtfsrt.TripUpdate_StopTimeEvent{
+							Time:  makeUnix(12),
+							Delay: ptr(int32(120)),
+						},
+					},
+				},
+				Timestamp: ptr(uint64(makeTime(1).Unix())),
+			},
+		},
+	}
+	if diff := cmp.Diff(wantEntities, msg.Entity, protocmp.Transform()); diff != "" {
+		t.Errorf("buildGtfsRealtimeFeedMessage() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// Builds a trip whose train arrives at the first station at the given time and takes
+// 3 minutes to travel between stations, like the trains in the mock GTFS static data.
+func scheduleTestTrip(route sourceapi.Route, direction sourceapi.Direction, firstArrival time.Time, stations ...sourceapi.Station) *trip {
+	tr := &trip{route: route, direction: direction}
+	for i, station := range stations {
+		tr.stops = append(tr.stops, tripStop{
+			station: station,
+			train: Train(&sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
+				Route:            route,
+				Direction:        direction,
+				ProjectedArrival: timestamppb.New(firstArrival.Add(time.Duration(3*i) * time.Minute)),
+				LastUpdated:      timestamppb.New(firstArrival),
+			}),
+		})
+	}
+	return tr
+}
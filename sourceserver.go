@@ -0,0 +1,168 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The time zone of all PATH stations.
+const pathTimezone = "America/New_York"
+
+// SourceServer implements the source API's gRPC Stations and Routes services using data from a source client.
+// This allows the binary to be used in place of the Razza gRPC API by other tools.
+//
+// Every request is passed through to the source client. To serve the data of a feed without making requests to
+// its source API, use the feed's SourceSnapshot as the source client. Pagination is not supported; all stations
+// and routes are returned in a single page. The schedule RPCs are not implemented.
+type SourceServer struct {
+	sourceapi.UnimplementedStationsServer
+	sourceapi.UnimplementedRoutesServer
+	sourceClient SourceClient
+}
+
+func NewSourceServer(sourceClient SourceClient) *SourceServer {
+	return &SourceServer{sourceClient: sourceClient}
+}
+
+// Register registers the Stations and Routes services on the gRPC server.
+func (s *SourceServer) Register(server *grpc.Server) {
+	sourceapi.RegisterStationsServer(server, s)
+	sourceapi.RegisterRoutesServer(server, s)
+}
+
+func (s *SourceServer) ListStations(ctx context.Context, _ *sourceapi.ListStationsRequest) (*sourceapi.ListStationsResponse, error) {
+	stationToStopId, err := s.sourceClient.GetStationToStopId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get stations: %s", err)
+	}
+	response := &sourceapi.ListStationsResponse{}
+	for station, stopId := range stationToStopId {
+		response.Stations = append(response.Stations, buildStationData(station, stopId))
+	}
+	sort.Slice(response.Stations, func(i, j int) bool {
+		return response.Stations[i].Station < response.Stations[j].Station
+	})
+	return response, nil
+}
+
+func (s *SourceServer) GetStation(ctx context.Context, request *sourceapi.GetStationRequest) (*sourceapi.StationData, error) {
+	stationToStopId, err := s.sourceClient.GetStationToStopId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get stations: %s", err)
+	}
+	stopId, ok := stationToStopId[request.Station]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown station %s", request.Station)
+	}
+	return buildStationData(request.Station, stopId), nil
+}
+
+func (s *SourceServer) GetUpcomingTrains(ctx context.Context, request *sourceapi.GetUpcomingTrainsRequest) (*sourceapi.GetUpcomingTrainsResponse, error) {
+	stationToStopId, err := s.sourceClient.GetStationToStopId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get stations: %s", err)
+	}
+	if _, ok := stationToStopId[request.Station]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown station %s", request.Station)
+	}
+	trains, err := s.sourceClient.GetTrainsAtStation(ctx, request.Station)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get upcoming trains: %s", err)
+	}
+	response := &sourceapi.GetUpcomingTrainsResponse{}
+	for _, train := range trains {
+		response.UpcomingTrains = append(response.UpcomingTrains, train)
+	}
+	return response, nil
+}
+
+func (s *SourceServer) ListRoutes(ctx context.Context, _ *sourceapi.ListRoutesRequest) (*sourceapi.ListRoutesResponse, error) {
+	routeToRouteId, err := s.sourceClient.GetRouteToRouteId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get routes: %s", err)
+	}
+	response := &sourceapi.ListRoutesResponse{}
+	for route, routeId := range routeToRouteId {
+		response.Routes = append(response.Routes, buildRouteData(route, routeId))
+	}
+	sort.Slice(response.Routes, func(i, j int) bool {
+		return response.Routes[i].Route < response.Routes[j].Route
+	})
+	return response, nil
+}
+
+func (s *SourceServer) GetRoute(ctx context.Context, request *sourceapi.GetRouteRequest) (*sourceapi.RouteData, error) {
+	routeToRouteId, err := s.sourceClient.GetRouteToRouteId(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get routes: %s", err)
+	}
+	routeId, ok := routeToRouteId[request.Route]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown route %s", request.Route)
+	}
+	return buildRouteData(request.Route, routeId), nil
+}
+
+func buildStationData(station sourceapi.Station, stopId string) *sourceapi.StationData {
+	return &sourceapi.StationData{
+		Station:  station,
+		Id:       stopId,
+		Name:     sourceStationToName[station],
+		Timezone: pathTimezone,
+	}
+}
+
+func buildRouteData(route sourceapi.Route, routeId string) *sourceapi.RouteData {
+	metadata := sourceRouteToMetadata[route]
+	routeData := &sourceapi.RouteData{
+		Route: route,
+		Id:    routeId,
+		Name:  metadata.longName,
+		Color: metadata.color,
+	}
+	for _, direction := range []sourceapi.Direction{sourceapi.Direction_TO_NJ, sourceapi.Direction_TO_NY} {
+		stations := stationsInDirection(route, direction)
+		if len(stations) == 0 {
+			continue
+		}
+		routeData.Lines = append(routeData.Lines, &sourceapi.RouteData_RouteLine{
+			DisplayName: fmt.Sprintf("%s - %s", sourceStationToName[stations[0]], sourceStationToName[stations[len(stations)-1]]),
+			Headsign:    defaultHeadsign(route, direction),
+			Direction:   direction,
+		})
+	}
+	return routeData
+}
+
+// SourceSnapshot returns a source client that serves the static and realtime data used by the most recent update
+// of the feed. Unlike the feed's source client it never makes requests to the source API, so it can be used to
+// serve the data to other clients without putting load on the source API.
+func (f *Feed) SourceSnapshot() SourceClient {
+	return feedSnapshotSourceClient{feed: f}
+}
+
+type feedSnapshotSourceClient struct {
+	feed *Feed
+}
+
+func (c feedSnapshotSourceClient) GetStationToStopId(context.Context) (map[sourceapi.Station]string, error) {
+	return c.feed.get().staticData.stationToStopId, nil
+}
+
+func (c feedSnapshotSourceClient) GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error) {
+	return c.feed.get().staticData.routeToRouteId, nil
+}
+
+func (c feedSnapshotSourceClient) GetTrainsAtStation(_ context.Context, station sourceapi.Station) ([]Train, error) {
+	data := c.feed.get()
+	if _, ok := data.stationToLastUpdated[station]; !ok {
+		return nil, fmt.Errorf("the trains at %s haven't been retrieved from the source API", station)
+	}
+	return data.realtimeData[station], nil
+}
@@ -0,0 +1,197 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestSourceServer(t *testing.T) {
+	train := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN:           stopIDHoboken,
+			sourceapi.Station_FOURTEENTH_STREET: stopID14St,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {train},
+		},
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	NewSourceServer(&client).Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() err got=%v, want=<nil>", err)
+	}
+	defer conn.Close()
+	stations := sourceapi.NewStationsClient(conn)
+	routes := sourceapi.NewRoutesClient(conn)
+	ctx := context.Background()
+
+	hoboken := &sourceapi.StationData{
+		Station:  sourceapi.Station_HOBOKEN,
+		Id:       stopIDHoboken,
+		Name:     "Hoboken",
+		Timezone: "America/New_York",
+	}
+	hob33 := &sourceapi.RouteData{
+		Route: sourceapi.Route_HOB_33,
+		Id:    routeID1,
+		Name:  "Hoboken - 33rd Street",
+		Color: "4D92FB",
+		Lines: []*sourceapi.RouteData_RouteLine{
+			{DisplayName: "33rd Street - Hoboken", Headsign: "Hoboken", Direction: sourceapi.Direction_TO_NJ},
+			{DisplayName: "Hoboken - 33rd Street", Headsign: "33rd Street", Direction: sourceapi.Direction_TO_NY},
+		},
+	}
+	for _, tc := range []struct {
+		name     string
+		call     func() (proto.Message, error)
+		want     proto.Message
+		wantCode codes.Code
+	}{
+		{
+			name: "list stations",
+			call: func() (proto.Message, error) {
+				return stations.ListStations(ctx, &sourceapi.ListStationsRequest{})
+			},
+			want: &sourceapi.ListStationsResponse{
+				Stations: []*sourceapi.StationData{
+					hoboken,
+					{
+						Station:  sourceapi.Station_FOURTEENTH_STREET,
+						Id:       stopID14St,
+						Name:     "14th Street",
+						Timezone: "America/New_York",
+					},
+				},
+			},
+		},
+		{
+			name: "get station",
+			call: func() (proto.Message, error) {
+				return stations.GetStation(ctx, &sourceapi.GetStationRequest{Station: sourceapi.Station_HOBOKEN})
+			},
+			want: hoboken,
+		},
+		{
+			name: "get unknown station",
+			call: func() (proto.Message, error) {
+				return stations.GetStation(ctx, &sourceapi.GetStationRequest{Station: sourceapi.Station_NEWARK})
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "get upcoming trains",
+			call: func() (proto.Message, error) {
+				return stations.GetUpcomingTrains(ctx, &sourceapi.GetUpcomingTrainsRequest{Station: sourceapi.Station_HOBOKEN})
+			},
+			want: &sourceapi.GetUpcomingTrainsResponse{
+				UpcomingTrains: []*sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{train},
+			},
+		},
+		{
+			name: "get upcoming trains source error",
+			call: func() (proto.Message, error) {
+				return stations.GetUpcomingTrains(ctx, &sourceapi.GetUpcomingTrainsRequest{Station: sourceapi.Station_FOURTEENTH_STREET})
+			},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name: "list routes",
+			call: func() (proto.Message, error) {
+				return routes.ListRoutes(ctx, &sourceapi.ListRoutesRequest{})
+			},
+			want: &sourceapi.ListRoutesResponse{Routes: []*sourceapi.RouteData{hob33}},
+		},
+		{
+			name: "get route",
+			call: func() (proto.Message, error) {
+				return routes.GetRoute(ctx, &sourceapi.GetRouteRequest{Route: sourceapi.Route_HOB_33})
+			},
+			want: hob33,
+		},
+		{
+			name: "get unknown route",
+			call: func() (proto.Message, error) {
+				return routes.GetRoute(ctx, &sourceapi.GetRouteRequest{Route: sourceapi.Route_NWK_WTC})
+			},
+			wantCode: codes.NotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.call()
+
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("status code got=%s, want=%s (err=%v)", code, tc.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFeed_SourceSnapshot(t *testing.T) {
+	ctx := context.Background()
+	client := newUnavailableSourceClient()
+	client.down = false
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	feed, err := NewFeed(ctx, c, 5*time.Second, client, func(*gtfsrt.FeedMessage, []error) {})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	// The snapshot is served without making requests to the source.
+	client.down = true
+	snapshot := feed.SourceSnapshot()
+
+	stationToStopId, err := snapshot.GetStationToStopId(ctx)
+	if err != nil {
+		t.Fatalf("GetStationToStopId() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(client.stationToStopID, stationToStopId); diff != "" {
+		t.Errorf("GetStationToStopId() mismatch (-want +got):\n%s", diff)
+	}
+	routeToRouteId, err := snapshot.GetRouteToRouteId(ctx)
+	if err != nil {
+		t.Fatalf("GetRouteToRouteId() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(client.routeToRouteID, routeToRouteId); diff != "" {
+		t.Errorf("GetRouteToRouteId() mismatch (-want +got):\n%s", diff)
+	}
+	trains, err := snapshot.GetTrainsAtStation(ctx, sourceapi.Station_HOBOKEN)
+	if err != nil {
+		t.Fatalf("GetTrainsAtStation() err got=%v, want=<nil>", err)
+	}
+	if diff := cmp.Diff(client.stationToTrains[sourceapi.Station_HOBOKEN], trains, protocmp.Transform()); diff != "" {
+		t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := snapshot.GetTrainsAtStation(ctx, sourceapi.Station_NEWARK); err == nil {
+		t.Errorf("GetTrainsAtStation(NEWARK) err got=<nil>, want an error for a station that wasn't retrieved")
+	}
+}
@@ -0,0 +1,74 @@
+package pathgtfsrt
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// The HTTP response header containing the age in seconds of the oldest station data in the feed.
+const DataAgeHeader = "X-Data-Age"
+
+// WithMaxDataAge makes the feed drop the trains at a station once the station's data is older than the max age.
+//
+// When the source API fails to return the trains at a station, the feed keeps using the trains from the last
+// successful request. Without a max age these are kept indefinitely.
+func WithMaxDataAge(maxAge time.Duration) FeedOption {
+	return func(o *feedOptions) {
+		o.maxDataAge = maxAge
+	}
+}
+
+// Removes stale data from the realtime data.
+//
+// Trains that left their station more than stoppedAtWindow ago are pruned; trains that left more recently are
+// kept so that vehicle positions can report them as stopped at the station. If the max age is positive, all
+// trains are dropped at stations whose data was last retrieved longer ago than the max age.
+func expireRealtimeData(data map[sourceapi.Station][]Train, stationToLastUpdated map[sourceapi.Station]time.Time, now time.Time, maxAge time.Duration) {
+	for station, trains := range data {
+		if maxAge > 0 && now.Sub(stationToLastUpdated[station]) > maxAge {
+			fmt.Printf("Dropping the data for station %s, which was last updated %s ago\n", station, now.Sub(stationToLastUpdated[station]))
+			delete(data, station)
+			continue
+		}
+		var current []Train
+		for _, train := range trains {
+			if train.ProjectedArrival != nil && now.Sub(train.ProjectedArrival.AsTime()) > stoppedAtWindow {
+				continue
+			}
+			current = append(current, train)
+		}
+		if len(current) != len(trains) {
+			data[station] = current
+		}
+	}
+}
+
+// DataAges returns how long ago the trains at each station were last retrieved from the source API.
+// Stations whose trains have never been retrieved are omitted.
+func (f *Feed) DataAges() map[sourceapi.Station]time.Duration {
+	now := f.clock.Now()
+	ages := map[sourceapi.Station]time.Duration{}
+	for station, lastUpdated := range f.get().stationToLastUpdated {
+		ages[station] = now.Sub(lastUpdated)
+	}
+	return ages
+}
+
+// DataAge returns the age of the oldest station data in the feed.
+func (f *Feed) DataAge() time.Duration {
+	var result time.Duration
+	for _, age := range f.DataAges() {
+		if age > result {
+			result = age
+		}
+	}
+	return result
+}
+
+func (f *Feed) setDataAgeHeader(w http.ResponseWriter) {
+	w.Header().Set(DataAgeHeader, strconv.Itoa(int(f.DataAge().Seconds())))
+}
@@ -0,0 +1,124 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestExpireRealtimeData(t *testing.T) {
+	now := makeTime(10)
+	departed := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 9, 5)
+	stoppedAt := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 10, 5)
+	upcoming := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NJ, 15, 5)
+	for _, tc := range []struct {
+		name        string
+		data        map[sourceapi.Station][]Train
+		lastUpdated time.Time
+		maxAge      time.Duration
+		want        map[sourceapi.Station][]Train
+	}{
+		{
+			name:        "departed trains are pruned",
+			data:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {departed, stoppedAt, upcoming}},
+			lastUpdated: now,
+			want:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {stoppedAt, upcoming}},
+		},
+		{
+			name:        "stale data is kept without a max age",
+			data:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {upcoming}},
+			lastUpdated: makeTime(0),
+			want:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {upcoming}},
+		},
+		{
+			name:        "data within the max age is kept",
+			data:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {upcoming}},
+			lastUpdated: makeTime(5),
+			maxAge:      5 * time.Minute,
+			want:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {upcoming}},
+		},
+		{
+			name:        "data older than the max age is dropped",
+			data:        map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {upcoming}},
+			lastUpdated: makeTime(4),
+			maxAge:      5 * time.Minute,
+			want:        map[sourceapi.Station][]Train{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stationToLastUpdated := map[sourceapi.Station]time.Time{sourceapi.Station_HOBOKEN: tc.lastUpdated}
+
+			expireRealtimeData(tc.data, stationToLastUpdated, now, tc.maxAge)
+
+			if diff := cmp.Diff(tc.want, tc.data, protocmp.Transform()); diff != "" {
+				t.Errorf("expireRealtimeData() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFeed_MaxDataAge(t *testing.T) {
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_FOURTEENTH_STREET: stopID14St,
+			sourceapi.Station_HOBOKEN:           stopIDHoboken,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_FOURTEENTH_STREET: {},
+			sourceapi.Station_HOBOKEN: {
+				sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 15, 0),
+			},
+		},
+	}
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	numEntities := make(chan int, 1)
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, &client, func(msg *gtfsrt.FeedMessage, _ []error) {
+		numEntities <- len(msg.Entity)
+	}, WithMaxDataAge(10*time.Second))
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	if got := <-numEntities; got != 1 {
+		t.Fatalf("number of entities got=%d, want=1", got)
+	}
+
+	// Requests for Hoboken fail from now on.
+	client.stationToTrains = map[sourceapi.Station][]Train{sourceapi.Station_FOURTEENTH_STREET: {}}
+	for _, tc := range []struct {
+		wantEntities      int
+		wantHobokenAge    time.Duration
+		wantDataAgeHeader string
+	}{
+		{wantEntities: 1, wantHobokenAge: 5 * time.Second, wantDataAgeHeader: "5"},
+		{wantEntities: 1, wantHobokenAge: 10 * time.Second, wantDataAgeHeader: "10"},
+		{wantEntities: 0, wantHobokenAge: 15 * time.Second, wantDataAgeHeader: "15"},
+	} {
+		c.Add(5 * time.Second)
+		if got := <-numEntities; got != tc.wantEntities {
+			t.Errorf("number of entities got=%d, want=%d", got, tc.wantEntities)
+		}
+		ages := feed.DataAges()
+		if got := ages[sourceapi.Station_HOBOKEN]; got != tc.wantHobokenAge {
+			t.Errorf("DataAges()[HOBOKEN] got=%s, want=%s", got, tc.wantHobokenAge)
+		}
+		if got := ages[sourceapi.Station_FOURTEENTH_STREET]; got != 0 {
+			t.Errorf("DataAges()[FOURTEENTH_STREET] got=%s, want=0s", got)
+		}
+		w := httptest.NewRecorder()
+		feed.ServeHTTP(w, httptest.NewRequest("GET", "/gtfsrt", nil))
+		if got := w.Header().Get(DataAgeHeader); got != tc.wantDataAgeHeader {
+			t.Errorf("%s header got=%q, want=%q", DataAgeHeader, got, tc.wantDataAgeHeader)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// WithStaticDataRefresh makes the feed periodically retrieve the static data, the stop ID of each station and
+// the route ID of each route, from the source API again.
+//
+// Without this the static data is only retrieved when the feed is created, so changes to it on the source API
+// require a restart. The static data is retrieved in the background, and the next update of the feed uses it.
+// Changes are logged and counted in the feed's StaticDataStats. If a station is removed, its
+// trains are dropped from the feed. If the static data can't be retrieved, the previous static data is kept.
+func WithStaticDataRefresh(period time.Duration) FeedOption {
+	return func(o *feedOptions) {
+		o.staticDataRefreshPeriod = period
+	}
+}
+
+// StaticDataStats describe the static data refreshes done by a feed since it was created.
+type StaticDataStats struct {
+	// When the static data was last retrieved from the source API. This is zero if it never has been.
+	LastRefreshed time.Time
+	// The number of failed attempts to retrieve the static data.
+	Failures uint64
+	// The number of stations and routes added, removed, or whose stop or route ID changed.
+	StationsAdded   uint64
+	StationsRemoved uint64
+	StationsChanged uint64
+	RoutesAdded     uint64
+	RoutesRemoved   uint64
+	RoutesChanged   uint64
+}
+
+// StaticDataStats returns the stats of the feed's static data refreshes.
+func (f *Feed) StaticDataStats() StaticDataStats {
+	return f.staticData.current.Load().stats
+}
+
+// A version of the static data of a feed. Versions are never modified; a refresh stores a new version.
+type staticDataVersion struct {
+	data staticData
+	// Whether the data is the fallback data because it couldn't be retrieved from the source API.
+	fallback bool
+	stats    StaticDataStats
+}
+
+// Refreshes the static data of a feed in its own goroutine. The updates of the feed read the current version.
+type staticDataRefresher struct {
+	clock            clock.Clock
+	source           staticDataSource
+	platformResolver *PlatformResolver
+	current          atomic.Pointer[staticDataVersion]
+}
+
+// Refreshes the static data on each tick of the ticker until the context is cancelled.
+//
+// While the fallback static data is in use the ticker should tick every update period, so that the feed recovers
+// quickly. Once the static data has been retrieved, the ticker is reset to the refresh period, or stopped if
+// refreshes are disabled.
+func (r *staticDataRefresher) run(ctx context.Context, ticker *clock.Ticker, period time.Duration) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			wasFallback := r.current.Load().fallback
+			r.refresh(ctx)
+			if !wasFallback || r.current.Load().fallback {
+				continue
+			}
+			if period <= 0 {
+				return
+			}
+			ticker.Reset(period)
+		}
+	}
+}
+
+// Retrieves the static data from the source API and stores it as the current version. If it can't be retrieved,
+// the current static data is kept.
+func (r *staticDataRefresher) refresh(ctx context.Context) {
+	old := r.current.Load()
+	new := *old
+	data, err := getStaticData(ctx, r.source)
+	if err == nil && len(data.stations) == 0 {
+		err = fmt.Errorf("the source API returned no stations")
+	}
+	if err != nil {
+		new.stats.Failures++
+		fmt.Println("There was an error when retrieving static data:", err)
+	} else {
+		if old.fallback {
+			fmt.Println("Retrieved static data from the source API; no longer using the fallback static data")
+		}
+		data.platformResolver = r.platformResolver
+		recordStaticDataChanges(old.data, data, &new.stats)
+		new.data = data
+		new.fallback = false
+		new.stats.LastRefreshed = r.clock.Now()
+	}
+	r.current.Store(&new)
+}
+
+// The differences between two versions of a mapping from stations or routes to IDs.
+type mappingDiff[K comparable] struct {
+	added   []K
+	removed []K
+	changed []K
+}
+
+func diffMapping[K interface {
+	comparable
+	fmt.Stringer
+}](old, new map[K]string) mappingDiff[K] {
+	var d mappingDiff[K]
+	for k, newId := range new {
+		oldId, ok := old[k]
+		if !ok {
+			d.added = append(d.added, k)
+			fmt.Printf("Static data: %s added with ID %q\n", k, newId)
+		} else if oldId != newId {
+			d.changed = append(d.changed, k)
+			fmt.Printf("Static data: ID of %s changed from %q to %q\n", k, oldId, newId)
+		}
+	}
+	for k, oldId := range old {
+		if _, ok := new[k]; !ok {
+			d.removed = append(d.removed, k)
+			fmt.Printf("Static data: %s with ID %q removed\n", k, oldId)
+		}
+	}
+	for _, ks := range [][]K{d.added, d.removed, d.changed} {
+		sort.Slice(ks, func(i, j int) bool { return ks[i].String() < ks[j].String() })
+	}
+	return d
+}
+
+// Records the differences between the old and new static data in the stats.
+func recordStaticDataChanges(old, new staticData, stats *StaticDataStats) {
+	stations := diffMapping(old.stationToStopId, new.stationToStopId)
+	routes := diffMapping(old.routeToRouteId, new.routeToRouteId)
+	stats.StationsAdded += uint64(len(stations.added))
+	stats.StationsRemoved += uint64(len(stations.removed))
+	stats.StationsChanged += uint64(len(stations.changed))
+	stats.RoutesAdded += uint64(len(routes.added))
+	stats.RoutesRemoved += uint64(len(routes.removed))
+	stats.RoutesChanged += uint64(len(routes.changed))
+}
+
+// Removes the realtime data of stations that are not in the static data.
+func removeStaleStations(staticData staticData, data map[sourceapi.Station][]Train, stationToLastUpdated map[sourceapi.Station]time.Time) {
+	for station := range data {
+		if _, ok := staticData.stationToStopId[station]; !ok {
+			delete(data, station)
+		}
+	}
+	for station := range stationToLastUpdated {
+		if _, ok := staticData.stationToStopId[station]; !ok {
+			delete(stationToLastUpdated, station)
+		}
+	}
+}
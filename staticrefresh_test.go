@@ -0,0 +1,152 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRecordStaticDataChanges(t *testing.T) {
+	old := staticData{
+		stationToStopId: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN:           stopIDHoboken,
+			sourceapi.Station_FOURTEENTH_STREET: stopID14St,
+		},
+		routeToRouteId: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+	}
+	new := staticData{
+		stationToStopId: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: "new-hoboken",
+			sourceapi.Station_NEWARK:  "newark",
+		},
+		routeToRouteId: map[sourceapi.Route]string{
+			sourceapi.Route_NWK_WTC: "new-route",
+		},
+	}
+	var stats StaticDataStats
+
+	recordStaticDataChanges(old, new, &stats)
+
+	wantStats := StaticDataStats{
+		StationsAdded:   1,
+		StationsRemoved: 1,
+		StationsChanged: 1,
+		RoutesAdded:     1,
+		RoutesRemoved:   1,
+	}
+	if diff := cmp.Diff(wantStats, stats); diff != "" {
+		t.Errorf("stats mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRemoveStaleStations(t *testing.T) {
+	train := sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 15, 0)
+	staticData := staticData{
+		stationToStopId: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: stopIDHoboken,
+		},
+	}
+	data := map[sourceapi.Station][]Train{
+		sourceapi.Station_HOBOKEN:           {train},
+		sourceapi.Station_FOURTEENTH_STREET: {train},
+	}
+	stationToLastUpdated := map[sourceapi.Station]time.Time{
+		sourceapi.Station_HOBOKEN:           makeTime(0),
+		sourceapi.Station_FOURTEENTH_STREET: makeTime(0),
+	}
+
+	removeStaleStations(staticData, data, stationToLastUpdated)
+
+	if diff := cmp.Diff(map[sourceapi.Station][]Train{sourceapi.Station_HOBOKEN: {train}}, data, protocmp.Transform()); diff != "" {
+		t.Errorf("realtime data mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[sourceapi.Station]time.Time{sourceapi.Station_HOBOKEN: makeTime(0)}, stationToLastUpdated); diff != "" {
+		t.Errorf("last updated times mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// Waits for the static data refresh, which runs in its own goroutine, to reach the expected stats.
+func waitForStaticDataStats(t *testing.T, feed *Feed, done func(StaticDataStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done(feed.StaticDataStats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the static data refresh; StaticDataStats() got=%+v", feed.StaticDataStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFeed_StaticDataRefresh(t *testing.T) {
+	client := newUnavailableSourceClient()
+	client.down = false
+	client.stationToStopID[sourceapi.Station_FOURTEENTH_STREET] = stopID14St
+	client.stationToTrains[sourceapi.Station_FOURTEENTH_STREET] = []Train{
+		sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NJ, 15, 0),
+	}
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	numEntities := make(chan int, 1)
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, client, func(msg *gtfsrt.FeedMessage, _ []error) {
+		numEntities <- len(msg.Entity)
+	}, WithStaticDataRefresh(10*time.Second))
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	if got := <-numEntities; got != 2 {
+		t.Fatalf("number of entities got=%d, want=2", got)
+	}
+
+	// 14th Street is removed from the source API, but the static data isn't refreshed yet.
+	client.stationToStopID = map[sourceapi.Station]string{sourceapi.Station_HOBOKEN: stopIDHoboken}
+	c.Add(5 * time.Second)
+	if got := <-numEntities; got != 2 {
+		t.Errorf("number of entities before refresh got=%d, want=2", got)
+	}
+
+	// The refresh fails, so the previous static data is kept.
+	client.down = true
+	c.Add(5 * time.Second)
+	if got := <-numEntities; got != 2 {
+		t.Errorf("number of entities after failed refresh got=%d, want=2", got)
+	}
+	waitForStaticDataStats(t, feed, func(stats StaticDataStats) bool { return stats.Failures == 1 })
+	if got := feed.StaticDataStats().LastRefreshed; got != makeTime(0) {
+		t.Errorf("StaticDataStats().LastRefreshed got=%s, want=%s", got, makeTime(0))
+	}
+
+	client.down = false
+	c.Add(5 * time.Second)
+	if got := <-numEntities; got != 2 {
+		t.Errorf("number of entities before next refresh got=%d, want=2", got)
+	}
+	// The update at the time of the refresh may run before or after the refresh, so only the next update
+	// is guaranteed to use the new static data.
+	c.Add(5 * time.Second)
+	<-numEntities
+	refreshTime := c.Now()
+	waitForStaticDataStats(t, feed, func(stats StaticDataStats) bool { return stats.LastRefreshed == refreshTime })
+	c.Add(5 * time.Second)
+	if got := <-numEntities; got != 1 {
+		t.Errorf("number of entities after refresh got=%d, want=1", got)
+	}
+	wantStats := StaticDataStats{
+		LastRefreshed:   refreshTime,
+		Failures:        1,
+		StationsRemoved: 1,
+	}
+	if diff := cmp.Diff(wantStats, feed.StaticDataStats()); diff != "" {
+		t.Errorf("StaticDataStats() mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := feed.DataAges()[sourceapi.Station_FOURTEENTH_STREET]; ok {
+		t.Errorf("DataAges() contains the removed station FOURTEENTH_STREET")
+	}
+}
@@ -0,0 +1,149 @@
+package pathgtfsrt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// FeedStatus describes the health of the feed. It is the response of the status page.
+type FeedStatus struct {
+	BuildNumber string `json:"build_number"`
+	// A description of the source API currently in use.
+	SourceApi string `json:"source_api"`
+	Ready     bool   `json:"ready"`
+	// When the feed was last updated, and when the trains at any station were last retrieved from the source API.
+	LastUpdate           time.Time  `json:"last_update"`
+	LastSuccessfulUpdate *time.Time `json:"last_successful_update,omitempty"`
+	// Whether the feed is using the fallback static data because it couldn't be retrieved from the source API.
+	FallbackStaticData bool            `json:"fallback_static_data"`
+	Stations           []StationStatus `json:"stations"`
+}
+
+// StationStatus describes the realtime data of a station.
+type StationStatus struct {
+	// The station's lowercase source API name, for example hoboken.
+	Station string `json:"station"`
+	StopId  string `json:"stop_id"`
+	// When the trains at the station were last retrieved from the source API.
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// The most recent error retrieving the trains at the station, which may be older than the last success.
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	NumTrains     int        `json:"num_trains"`
+}
+
+// An error retrieving the trains at a station.
+type stationRequestError struct {
+	station sourceapi.Station
+	err     error
+}
+
+func (e *stationRequestError) Error() string {
+	return fmt.Sprintf("%s: %s", e.station, e.err)
+}
+
+func (e *stationRequestError) Unwrap() error {
+	return e.err
+}
+
+// The most recent error retrieving the trains at a station.
+type stationError struct {
+	time time.Time
+	err  string
+}
+
+func recordStationErrors(stationToLastError map[sourceapi.Station]stationError, errs []error, now time.Time) {
+	for _, err := range errs {
+		var stationErr *stationRequestError
+		if errors.As(err, &stationErr) {
+			stationToLastError[stationErr.station] = stationError{time: now, err: stationErr.err.Error()}
+		}
+	}
+}
+
+func buildStationStatuses(staticData staticData, data map[sourceapi.Station][]Train, stationToLastUpdated map[sourceapi.Station]time.Time, stationToLastError map[sourceapi.Station]stationError) []StationStatus {
+	var statuses []StationStatus
+	for _, station := range staticData.stations {
+		status := StationStatus{
+			Station:   strings.ToLower(station.String()),
+			StopId:    staticData.stationToStopId[station],
+			NumTrains: len(data[station]),
+		}
+		if lastUpdated, ok := stationToLastUpdated[station]; ok {
+			status.LastSuccess = ptr(lastUpdated)
+		}
+		if lastError, ok := stationToLastError[station]; ok {
+			status.LastError = lastError.err
+			status.LastErrorTime = ptr(lastError.time)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Returns when the trains at any station were last retrieved from the source API, or zero if they never have been.
+func (data feedData) lastSuccessfulUpdate() time.Time {
+	var result time.Time
+	for _, lastUpdated := range data.stationToLastUpdated {
+		if lastUpdated.After(result) {
+			result = lastUpdated
+		}
+	}
+	return result
+}
+
+// Ready returns whether the feed is serving current data: it is using static data from the source API, and the
+// trains at some station were retrieved from the source API within the max number of update periods.
+// If the max number of update periods isn't positive, it is enough that the trains at some station have ever
+// been retrieved.
+func (f *Feed) Ready(maxUpdatePeriods int) bool {
+	return f.ready(f.get(), maxUpdatePeriods)
+}
+
+func (f *Feed) ready(data feedData, maxUpdatePeriods int) bool {
+	lastSuccessfulUpdate := data.lastSuccessfulUpdate()
+	if data.fallbackStaticData || lastSuccessfulUpdate.IsZero() {
+		return false
+	}
+	return maxUpdatePeriods <= 0 || f.clock.Since(lastSuccessfulUpdate) <= time.Duration(maxUpdatePeriods)*f.updatePeriod
+}
+
+// Status returns the status of the feed. The source API description is the one provided, and readiness is
+// determined as in Ready.
+func (f *Feed) Status(sourceApi string, maxUpdatePeriods int) FeedStatus {
+	data := f.get()
+	status := FeedStatus{
+		BuildNumber:        BuildNumber,
+		SourceApi:          sourceApi,
+		Ready:              f.ready(data, maxUpdatePeriods),
+		LastUpdate:         data.lastUpdate,
+		FallbackStaticData: data.fallbackStaticData,
+		Stations:           data.stationStatuses,
+	}
+	if lastSuccessfulUpdate := data.lastSuccessfulUpdate(); !lastSuccessfulUpdate.IsZero() {
+		status.LastSuccessfulUpdate = &lastSuccessfulUpdate
+	}
+	return status
+}
+
+// StatusHandler returns a handler that responds with the status of the feed in JSON.
+//
+// The source API function returns a description of the source API currently in use.
+func (f *Feed) StatusHandler(sourceApi func() string, maxUpdatePeriods int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.MarshalIndent(f.Status(sourceApi(), maxUpdatePeriods), "", "  ")
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		writeBytes(w, b)
+	})
+}
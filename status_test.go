@@ -0,0 +1,121 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestFeed_Status(t *testing.T) {
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_FOURTEENTH_STREET: stopID14St,
+			sourceapi.Station_HOBOKEN:           stopIDHoboken,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_FOURTEENTH_STREET: {},
+			sourceapi.Station_HOBOKEN: {
+				sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 15, 0),
+				sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 25, 0),
+			},
+		},
+	}
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	updated := make(chan struct{}, 1)
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, &client, func(*gtfsrt.FeedMessage, []error) {
+		updated <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	<-updated
+
+	// Requests for 14th Street fail from now on.
+	delete(client.stationToTrains, sourceapi.Station_FOURTEENTH_STREET)
+	c.Add(5 * time.Second)
+	<-updated
+
+	wantStatus := FeedStatus{
+		SourceApi:            "mock",
+		Ready:                true,
+		LastUpdate:           makeTime(0).Add(5 * time.Second),
+		LastSuccessfulUpdate: ptr(makeTime(0).Add(5 * time.Second)),
+		// Stations are in the order of the source API's enum, where HOBOKEN is before FOURTEENTH_STREET.
+		Stations: []StationStatus{
+			{
+				Station:     "hoboken",
+				StopId:      stopIDHoboken,
+				NumTrains:   2,
+				LastSuccess: ptr(makeTime(0).Add(5 * time.Second)),
+			},
+			{
+				Station:       "fourteenth_street",
+				StopId:        stopID14St,
+				LastSuccess:   ptr(makeTime(0)),
+				LastErrorTime: ptr(makeTime(0).Add(5 * time.Second)),
+			},
+		},
+	}
+	gotStatus := feed.Status("mock", 3)
+	if len(gotStatus.Stations) == 2 && gotStatus.Stations[1].LastError == "" {
+		t.Errorf("Status().Stations[1].LastError got=%q, want an error", gotStatus.Stations[1].LastError)
+	}
+	if diff := cmp.Diff(wantStatus, gotStatus, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".LastError"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("Status() mismatch (-want +got):\n%s", diff)
+	}
+
+	w := httptest.NewRecorder()
+	feed.StatusHandler(func() string { return "mock" }, 3).ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	var gotJSON FeedStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &gotJSON); err != nil {
+		t.Fatalf("failed to parse the status page %q: %v", w.Body.String(), err)
+	}
+	if diff := cmp.Diff(gotStatus, gotJSON); diff != "" {
+		t.Errorf("status page mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFeed_Ready(t *testing.T) {
+	client := newUnavailableSourceClient()
+	client.down = false
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	updated := make(chan struct{}, 1)
+	feed, err := NewFeed(context.Background(), c, 5*time.Second, client, func(*gtfsrt.FeedMessage, []error) {
+		updated <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	<-updated
+
+	client.down = true
+	for _, maxUpdatePeriods := range []int{0, 2} {
+		if got := feed.Ready(maxUpdatePeriods); !got {
+			t.Errorf("Ready(%d) before failed updates got=false, want=true", maxUpdatePeriods)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		c.Add(5 * time.Second)
+		<-updated
+		if got := feed.Ready(0); !got {
+			t.Errorf("Ready(0) after %d failed updates got=false, want=true", i+1)
+		}
+		if got, want := feed.Ready(2), i < 2; got != want {
+			t.Errorf("Ready(2) after %d failed updates got=%t, want=%t", i+1, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// The maximum duration to wait for a WebSocket client to receive a message before disconnecting it.
+const streamWriteTimeout = 10 * time.Second
+
+// broadcaster publishes feed messages to subscribers.
+//
+// Each feed message contains the full dataset, so a subscriber that hasn't received a message by the time the
+// next one is published doesn't need it. Slow subscribers therefore never block publishing: each subscriber has
+// room for one pending message, and an unreceived pending message is replaced by the newer one.
+type broadcaster struct {
+	mutex       sync.Mutex
+	latest      *gtfs.FeedMessage
+	subscribers map[chan *gtfs.FeedMessage]bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: map[chan *gtfs.FeedMessage]bool{}}
+}
+
+func (b *broadcaster) publish(msg *gtfs.FeedMessage) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.latest = msg
+	for c := range b.subscribers {
+		sendLatest(c, msg)
+	}
+}
+
+// Sends the message on a channel with a buffer of one, replacing the message in the buffer if there is one.
+// Must only be called with the broadcaster's mutex held, so that there is a single sender.
+func sendLatest(c chan *gtfs.FeedMessage, msg *gtfs.FeedMessage) {
+	select {
+	case c <- msg:
+		return
+	default:
+	}
+	select {
+	case <-c:
+	default:
+	}
+	c <- msg
+}
+
+func (b *broadcaster) latestMessage() *gtfs.FeedMessage {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.latest
+}
+
+func (b *broadcaster) subscribe(ctx context.Context) <-chan *gtfs.FeedMessage {
+	c := make(chan *gtfs.FeedMessage, 1)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers[c] = true
+	if b.latest != nil {
+		c <- b.latest
+	}
+	go func() {
+		<-ctx.Done()
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, c)
+		close(c)
+	}()
+	return c
+}
+
+// Subscribe returns a channel on which the GTFS realtime message built in each update is sent,
+// starting with the most recent message.
+//
+// Subscribers that fall behind skip messages rather than delaying the feed: only the most
+// recent unreceived message is kept. The subscription ends, and the channel is closed, when the
+// context is cancelled.
+// The messages are shared between subscribers and must not be modified.
+func (f *Feed) Subscribe(ctx context.Context) <-chan *gtfs.FeedMessage {
+	return f.broadcaster.subscribe(ctx)
+}
+
+// StreamHandler returns a handler that pushes the GTFS realtime data to clients as soon as it is updated.
+//
+// WebSocket clients receive each message as a binary frame containing the protobuf encoded message.
+// Other clients receive a Server-Sent Events stream in which each message is a `feed` event whose data
+// is the JSON encoded message.
+func (f *Feed) StreamHandler() http.Handler {
+	webSocketServer := websocket.Server{
+		// The feed is public, so connections from any origin are accepted.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ws.PayloadType = websocket.BinaryFrame
+			ctx, cancel := context.WithCancel(ws.Request().Context())
+			defer cancel()
+			// Clients aren't expected to send anything; reading is only used to detect that the client has gone.
+			go func() {
+				_, _ = io.Copy(io.Discard, ws)
+				cancel()
+			}()
+			for msg := range f.Subscribe(ctx) {
+				if err := ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+					return
+				}
+				if _, err := ws.Write(mustMarshal(msg)); err != nil {
+					return
+				}
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			webSocketServer.ServeHTTP(w, r)
+			return
+		}
+		serveServerSentEvents(w, f.Subscribe(r.Context()))
+	})
+}
+
+func serveServerSentEvents(w http.ResponseWriter, messages <-chan *gtfs.FeedMessage) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for msg := range messages {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			fmt.Println("Failed to encode feed message as JSON:", err)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: feed\nid: %d\ndata: %s\n\n", msg.GetHeader().GetTimestamp(), data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
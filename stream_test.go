@@ -0,0 +1,144 @@
+package pathgtfsrt
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := newBroadcaster()
+	message := func(timestamp uint64) *gtfsrt.FeedMessage {
+		return &gtfsrt.FeedMessage{Header: &gtfsrt.FeedHeader{Timestamp: ptr(timestamp)}}
+	}
+	b.publish(message(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	c := b.subscribe(ctx)
+
+	if got := (<-c).GetHeader().GetTimestamp(); got != 1 {
+		t.Errorf("initial message timestamp got=%d, want=1", got)
+	}
+
+	// A slow subscriber only receives the latest message.
+	b.publish(message(2))
+	b.publish(message(3))
+	if got := (<-c).GetHeader().GetTimestamp(); got != 3 {
+		t.Errorf("message timestamp got=%d, want=3", got)
+	}
+
+	cancel()
+	if _, ok := <-c; ok {
+		t.Errorf("channel open after cancel, want closed")
+	}
+	b.publish(message(4))
+}
+
+func TestStreamHandler(t *testing.T) {
+	c := clock.NewMock()
+	c.Set(makeTime(0))
+	updateSignal := make(chan struct{}, 1)
+	client := mockSourceClient{
+		stationToStopID: map[sourceapi.Station]string{
+			sourceapi.Station_HOBOKEN: stopIDHoboken,
+		},
+		routeToRouteID: map[sourceapi.Route]string{
+			sourceapi.Route_HOB_33: routeID1,
+		},
+		stationToTrains: map[sourceapi.Station][]Train{
+			sourceapi.Station_HOBOKEN: {sourceTrain(sourceapi.Route_HOB_33, sourceapi.Direction_TO_NY, 5, 0)},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed, err := NewFeed(ctx, c, 5*time.Second, &client, func(*gtfsrt.FeedMessage, []error) {
+		updateSignal <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("NewFeed() err got=%v, want=<nil>", err)
+	}
+	<-updateSignal
+	server := httptest.NewServer(feed.StreamHandler())
+	defer server.Close()
+
+	checkMessage := func(t *testing.T, msg *gtfsrt.FeedMessage, wantTime time.Time) {
+		if got, want := msg.GetHeader().GetTimestamp(), uint64(wantTime.Unix()); got != want {
+			t.Errorf("message timestamp got=%d, want=%d", got, want)
+		}
+		if got := len(msg.GetEntity()); got != 1 {
+			t.Errorf("len(message entities) got=%d, want=1", got)
+		}
+	}
+
+	t.Run("server-sent events", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("http.Get() err got=%v, want=<nil>", err)
+		}
+		defer resp.Body.Close()
+		if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Content-Type got=%q, want=%q", got, "text/event-stream")
+		}
+		reader := bufio.NewReader(resp.Body)
+		readEvent := func() *gtfsrt.FeedMessage {
+			var data string
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					t.Fatalf("ReadString() err got=%v, want=<nil>", err)
+				}
+				line = strings.TrimSuffix(line, "\n")
+				if line == "" {
+					break
+				}
+				if strings.HasPrefix(line, "data: ") {
+					data = strings.TrimPrefix(line, "data: ")
+				}
+			}
+			msg := &gtfsrt.FeedMessage{}
+			if err := protojson.Unmarshal([]byte(data), msg); err != nil {
+				t.Fatalf("protojson.Unmarshal() err got=%v, want=<nil>", err)
+			}
+			return msg
+		}
+
+		checkMessage(t, readEvent(), c.Now())
+		c.Add(5 * time.Second)
+		<-updateSignal
+		checkMessage(t, readEvent(), c.Now())
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+		if err != nil {
+			t.Fatalf("websocket.Dial() err got=%v, want=<nil>", err)
+		}
+		defer ws.Close()
+		readFrame := func() *gtfsrt.FeedMessage {
+			var b []byte
+			if err := websocket.Message.Receive(ws, &b); err != nil {
+				t.Fatalf("websocket.Message.Receive() err got=%v, want=<nil>", err)
+			}
+			msg := &gtfsrt.FeedMessage{}
+			if err := proto.Unmarshal(b, msg); err != nil {
+				t.Fatalf("proto.Unmarshal() err got=%v, want=<nil>", err)
+			}
+			return msg
+		}
+
+		checkMessage(t, readFrame(), c.Now())
+		c.Add(5 * time.Second)
+		<-updateSignal
+		checkMessage(t, readFrame(), c.Now())
+	})
+}
@@ -0,0 +1,115 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TimetableSourceClient is a source client that generates synthetic but plausible trains from a fixed timetable,
+// for use when testing against a stand-in for the source APIs.
+//
+// On every route in each direction a train leaves the first station once per headway, and takes the travel time to
+// get to each subsequent station. The departures of the routes are offset from each other so that they don't all
+// arrive at shared stations at the same time. Each station reports the trains arriving within the horizon, except
+// those terminating at the station.
+type TimetableSourceClient struct {
+	clock      clock.Clock
+	headway    time.Duration
+	travelTime time.Duration
+	horizon    time.Duration
+}
+
+func NewTimetableSourceClient(clock clock.Clock, headway, travelTime, horizon time.Duration) (*TimetableSourceClient, error) {
+	if headway <= 0 || travelTime <= 0 || horizon <= 0 {
+		return nil, fmt.Errorf("invalid timetable; the headway, travel time and horizon must be positive")
+	}
+	return &TimetableSourceClient{clock: clock, headway: headway, travelTime: travelTime, horizon: horizon}, nil
+}
+
+func (c *TimetableSourceClient) GetStationToStopId(context.Context) (map[sourceapi.Station]string, error) {
+	return sourceStationToGtfsStopId, nil
+}
+
+func (c *TimetableSourceClient) GetRouteToRouteId(context.Context) (map[sourceapi.Route]string, error) {
+	return sourceRouteToGtfsRouteId, nil
+}
+
+func (c *TimetableSourceClient) GetTrainsAtStation(_ context.Context, station sourceapi.Station) ([]Train, error) {
+	if _, ok := sourceStationToGtfsStopId[station]; !ok {
+		return nil, fmt.Errorf("unknown station %s", station)
+	}
+	now := c.clock.Now().Truncate(time.Second)
+	var trains []Train
+	for route := range sourceRouteToGtfsRouteId {
+		for _, direction := range []sourceapi.Direction{sourceapi.Direction_TO_NJ, sourceapi.Direction_TO_NY} {
+			stations := stationsInDirection(route, direction)
+			for i, s := range stations[:len(stations)-1] {
+				if s != station {
+					continue
+				}
+				for _, arrival := range c.arrivals(route, direction, i, now) {
+					trains = append(trains, c.buildTrain(route, direction, arrival, now))
+				}
+			}
+		}
+	}
+	sort.SliceStable(trains, func(i, j int) bool {
+		if !trains[i].ProjectedArrival.AsTime().Equal(trains[j].ProjectedArrival.AsTime()) {
+			return trains[i].ProjectedArrival.AsTime().Before(trains[j].ProjectedArrival.AsTime())
+		}
+		if trains[i].Route != trains[j].Route {
+			return trains[i].Route < trains[j].Route
+		}
+		return trains[i].Direction < trains[j].Direction
+	})
+	return trains, nil
+}
+
+// Returns the arrival times within the horizon at the station with the index in the route's list of stations in
+// the direction.
+func (c *TimetableSourceClient) arrivals(route sourceapi.Route, direction sourceapi.Direction, i int, now time.Time) []time.Time {
+	// Offsetting by the route and direction spreads the departures of the routes over the headway.
+	offset := c.headway * time.Duration(2*int(route)+int(direction)) / 16
+	firstDeparture := time.Unix(0, 0).Add(offset + time.Duration(i)*c.travelTime)
+	k := now.Sub(firstDeparture) / c.headway
+	arrival := firstDeparture.Add(k * c.headway)
+	for arrival.Before(now) {
+		arrival = arrival.Add(c.headway)
+	}
+	var arrivals []time.Time
+	for ; !arrival.After(now.Add(c.horizon)); arrival = arrival.Add(c.headway) {
+		arrivals = append(arrivals, arrival)
+	}
+	return arrivals
+}
+
+func (c *TimetableSourceClient) buildTrain(route sourceapi.Route, direction sourceapi.Direction, arrival, now time.Time) Train {
+	headsign := defaultHeadsign(route, direction)
+	return &sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
+		LineName:         headsign,
+		Headsign:         headsign,
+		Route:            route,
+		RouteDisplayName: sourceRouteToMetadata[route].longName,
+		Direction:        direction,
+		ProjectedArrival: timestamppb.New(arrival),
+		LineColors:       convertLineColorToLineColors(routeToPanynjLineColor(route)),
+		Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
+		LastUpdated:      timestamppb.New(now),
+	}
+}
+
+// Returns the line color of the route in the format used by the PANYNJ API.
+func routeToPanynjLineColor(route sourceapi.Route) string {
+	for lineColor, r := range panynjLineColorToRoute {
+		if r == route {
+			return lineColor
+		}
+	}
+	return sourceRouteToMetadata[route].color
+}
@@ -0,0 +1,95 @@
+package pathgtfsrt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTimetableSourceClient(t *testing.T) {
+	// With a headway of 16 minutes, NWK_WTC trains leave WTC 11 minutes and Newark 12 minutes after the start of each
+	// headway.
+	start := time.Unix(0, 0).Add(100000 * 16 * time.Minute)
+	nwkWtc := func(direction sourceapi.Direction, arrival time.Duration, now time.Time) Train {
+		headsign := "World Trade Center"
+		if direction == sourceapi.Direction_TO_NJ {
+			headsign = "Newark"
+		}
+		return &sourceapi.GetUpcomingTrainsResponse_UpcomingTrain{
+			LineName:         headsign,
+			Headsign:         headsign,
+			Route:            sourceapi.Route_NWK_WTC,
+			RouteDisplayName: "Newark - World Trade Center",
+			Direction:        direction,
+			ProjectedArrival: timestamppb.New(start.Add(arrival)),
+			LineColors:       []string{"#D93A30"},
+			Status:           sourceapi.GetUpcomingTrainsResponse_UpcomingTrain_ON_TIME,
+			LastUpdated:      timestamppb.New(now),
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		now     time.Time
+		station sourceapi.Station
+		want    []Train
+		wantErr bool
+	}{
+		{
+			name:    "intermediate station",
+			now:     start,
+			station: sourceapi.Station_HARRISON,
+			want: []Train{
+				nwkWtc(sourceapi.Direction_TO_NJ, 3*time.Minute, start),
+				nwkWtc(sourceapi.Direction_TO_NY, 14*time.Minute, start),
+				nwkWtc(sourceapi.Direction_TO_NJ, 19*time.Minute, start),
+			},
+		},
+		{
+			name:    "departed trains are omitted",
+			now:     start.Add(5 * time.Minute),
+			station: sourceapi.Station_HARRISON,
+			want: []Train{
+				nwkWtc(sourceapi.Direction_TO_NY, 14*time.Minute, start.Add(5*time.Minute)),
+				nwkWtc(sourceapi.Direction_TO_NJ, 19*time.Minute, start.Add(5*time.Minute)),
+			},
+		},
+		{
+			name:    "terminating trains are omitted",
+			now:     start,
+			station: sourceapi.Station_NEWARK,
+			want: []Train{
+				nwkWtc(sourceapi.Direction_TO_NY, 12*time.Minute, start),
+			},
+		},
+		{
+			name:    "unknown station",
+			now:     start,
+			station: sourceapi.Station_STATION_UNSPECIFIED,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := clock.NewMock()
+			c.Set(tc.now)
+			client, err := NewTimetableSourceClient(c, 16*time.Minute, 2*time.Minute, 20*time.Minute)
+			if err != nil {
+				t.Fatalf("NewTimetableSourceClient() err got=%v, want=<nil>", err)
+			}
+
+			got, err := client.GetTrainsAtStation(context.Background(), tc.station)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GetTrainsAtStation() err got=%v, wantErr=%t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("GetTrainsAtStation() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
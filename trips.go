@@ -0,0 +1,279 @@
+package pathgtfsrt
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// The maximum time a train is expected to take to travel between two consecutive stations on a route.
+// Arrivals further apart than this are never stitched into the same trip.
+const maxTravelTimePerStop = 10 * time.Minute
+
+// The stations served by each route, in order, when travelling in the TO_NY direction.
+// Trains travelling in the TO_NJ direction serve the same stations in the reverse order.
+var routeToStationsToNy = map[sourceapi.Route][]sourceapi.Station{
+	sourceapi.Route_NWK_WTC: {
+		sourceapi.Station_NEWARK,
+		sourceapi.Station_HARRISON,
+		sourceapi.Station_JOURNAL_SQUARE,
+		sourceapi.Station_GROVE_STREET,
+		sourceapi.Station_EXCHANGE_PLACE,
+		sourceapi.Station_WORLD_TRADE_CENTER,
+	},
+	sourceapi.Route_HOB_WTC: {
+		sourceapi.Station_HOBOKEN,
+		sourceapi.Station_NEWPORT,
+		sourceapi.Station_EXCHANGE_PLACE,
+		sourceapi.Station_WORLD_TRADE_CENTER,
+	},
+	sourceapi.Route_JSQ_33: {
+		sourceapi.Station_JOURNAL_SQUARE,
+		sourceapi.Station_GROVE_STREET,
+		sourceapi.Station_NEWPORT,
+		sourceapi.Station_CHRISTOPHER_STREET,
+		sourceapi.Station_NINTH_STREET,
+		sourceapi.Station_FOURTEENTH_STREET,
+		sourceapi.Station_TWENTY_THIRD_STREET,
+		sourceapi.Station_THIRTY_THIRD_STREET,
+	},
+	sourceapi.Route_HOB_33: {
+		sourceapi.Station_HOBOKEN,
+		sourceapi.Station_CHRISTOPHER_STREET,
+		sourceapi.Station_NINTH_STREET,
+		sourceapi.Station_FOURTEENTH_STREET,
+		sourceapi.Station_TWENTY_THIRD_STREET,
+		sourceapi.Station_THIRTY_THIRD_STREET,
+	},
+	sourceapi.Route_JSQ_33_HOB: {
+		sourceapi.Station_JOURNAL_SQUARE,
+		sourceapi.Station_GROVE_STREET,
+		sourceapi.Station_NEWPORT,
+		sourceapi.Station_HOBOKEN,
+		sourceapi.Station_CHRISTOPHER_STREET,
+		sourceapi.Station_NINTH_STREET,
+		sourceapi.Station_FOURTEENTH_STREET,
+		sourceapi.Station_TWENTY_THIRD_STREET,
+		sourceapi.Station_THIRTY_THIRD_STREET,
+	},
+	sourceapi.Route_NPT_HOB: {
+		sourceapi.Station_NEWPORT,
+		sourceapi.Station_HOBOKEN,
+	},
+}
+
+// Returns the stations served by a route in the order they are visited by trains travelling in the direction.
+//
+// If the route or direction is unknown, nil is returned.
+func stationsInDirection(route sourceapi.Route, direction sourceapi.Direction) []sourceapi.Station {
+	stations, ok := routeToStationsToNy[route]
+	if !ok {
+		return nil
+	}
+	switch direction {
+	case sourceapi.Direction_TO_NY:
+		return stations
+	case sourceapi.Direction_TO_NJ:
+		reversed := make([]sourceapi.Station, len(stations))
+		for i, station := range stations {
+			reversed[len(stations)-1-i] = station
+		}
+		return reversed
+	}
+	return nil
+}
+
+// A trip is a single train travelling along a route, along with its predicted arrivals at one or more stations.
+type trip struct {
+	id        string
+	route     sourceapi.Route
+	direction sourceapi.Direction
+	headsign  string
+	// The stops of the trip in the order they are visited.
+	stops []tripStop
+	// Only set if the feed matches trips to a GTFS static schedule.
+	schedule *tripSchedule
+}
+
+type tripStop struct {
+	station sourceapi.Station
+	train   Train
+}
+
+func (t *trip) lastStop() tripStop {
+	return t.stops[len(t.stops)-1]
+}
+
+// Returns the most recent LastUpdated time of all the predictions in the trip.
+func (t *trip) lastUpdated() int64 {
+	var result int64
+	for _, stop := range t.stops {
+		if s := stop.train.LastUpdated.Seconds; s > result {
+			result = s
+		}
+	}
+	return result
+}
+
+// Stitches the per-station trains in the realtime data into trips.
+//
+// Trains are correlated using their route, direction and normalized headsign, so that trains reported by
+// sources that spell the headsign differently are still correlated. Within each such group,
+// stations are processed in the order the route visits them and the arrivals at each station
+// are matched to the trips seen at the previous station, relying on the fact that trains on the
+// same line cannot overtake each other and must arrive at later stations at later times.
+// Trains that cannot be correlated with any other train become single stop trips.
+//
+// The returned trips are sorted by the arrival time at their first stop.
+func stitchTrips(staticData staticData, realtimeData map[sourceapi.Station][]Train) []*trip {
+	type groupKey struct {
+		route     sourceapi.Route
+		direction sourceapi.Direction
+		headsign  string
+	}
+	groups := map[groupKey]map[sourceapi.Station][]Train{}
+	var groupKeys []groupKey
+	for _, station := range staticData.stations {
+		for _, train := range realtimeData[station] {
+			if _, ok := staticData.routeToRouteId[train.Route]; !ok {
+				continue
+			}
+			if train.Direction != sourceapi.Direction_TO_NJ && train.Direction != sourceapi.Direction_TO_NY {
+				continue
+			}
+			if train.ProjectedArrival == nil {
+				continue
+			}
+			if train.LastUpdated == nil {
+				continue
+			}
+			key := groupKey{route: train.Route, direction: train.Direction, headsign: normalizeHeadsign(train.Headsign)}
+			if _, ok := groups[key]; !ok {
+				groups[key] = map[sourceapi.Station][]Train{}
+				groupKeys = append(groupKeys, key)
+			}
+			groups[key][station] = append(groups[key][station], train)
+		}
+	}
+	var trips []*trip
+	for _, key := range groupKeys {
+		newTrip := func(station sourceapi.Station, train Train) *trip {
+			t := &trip{
+				route:     key.route,
+				direction: key.direction,
+				headsign:  train.Headsign,
+				stops:     []tripStop{{station: station, train: train}},
+			}
+			trips = append(trips, t)
+			return t
+		}
+		stationToTrains := groups[key]
+		var frontier []*trip
+		frontierIdx := -1
+		for i, station := range stationsInDirection(key.route, key.direction) {
+			trains, ok := stationToTrains[station]
+			if !ok {
+				continue
+			}
+			delete(stationToTrains, station)
+			sortTrainsByArrival(trains)
+			offset := matchArrivals(frontier, trains, time.Duration(i-frontierIdx)*maxTravelTimePerStop)
+			var nextFrontier []*trip
+			for j, train := range trains {
+				if j < offset || j-offset >= len(frontier) {
+					nextFrontier = append(nextFrontier, newTrip(station, train))
+					continue
+				}
+				t := frontier[j-offset]
+				t.stops = append(t.stops, tripStop{station: station, train: train})
+				nextFrontier = append(nextFrontier, t)
+			}
+			frontier = nextFrontier
+			frontierIdx = i
+		}
+		// Trains at stations not on the route's known stopping pattern are left as single stop trips.
+		for _, station := range staticData.stations {
+			for _, train := range stationToTrains[station] {
+				newTrip(station, train)
+			}
+		}
+	}
+	sort.SliceStable(trips, func(i, j int) bool {
+		return trips[i].stops[0].train.ProjectedArrival.Seconds < trips[j].stops[0].train.ProjectedArrival.Seconds
+	})
+	return trips
+}
+
+// Map from the abbreviations used in headsigns to the words they abbreviate.
+var headsignAbbreviations = map[string]string{
+	"st":     "street",
+	"sq":     "square",
+	"ctr":    "center",
+	"centre": "center",
+	"wtc":    "world trade center",
+	"jsq":    "journal square",
+	"hob":    "hoboken",
+	"nwk":    "newark",
+}
+
+// Returns the headsign in lowercase, with punctuation removed and abbreviations expanded, so that different
+// spellings of the same headsign are equal.
+func normalizeHeadsign(headsign string) string {
+	words := strings.FieldsFunc(strings.ToLower(headsign), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		if expanded, ok := headsignAbbreviations[word]; ok {
+			words[i] = expanded
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Matches the trains arriving at a station with the trips whose last stop was the previous station.
+//
+// Both inputs must be sorted by arrival time. Because trains cannot overtake each other, the
+// matching preserves order: the first few trains at the station may have already passed the
+// previous station and are not matched, after which the trains are matched with the trips
+// in order. The returned offset is the number of trains that are not matched at the start; the
+// train at index j >= offset is matched with the trip at index j-offset, if it exists.
+//
+// Among all offsets that result in plausible travel times, the one that matches the most trains
+// is chosen, with ties broken by the shortest total travel time.
+func matchArrivals(trips []*trip, trains []Train, maxTravelTime time.Duration) int {
+	bestOffset := len(trains)
+	bestNumMatches := 0
+	var bestTotalTravelTime int64
+	for offset := 0; offset < len(trains); offset++ {
+		numMatches := 0
+		var totalTravelTime int64
+		valid := true
+		for j := offset; j < len(trains) && j-offset < len(trips); j++ {
+			travelTime := trains[j].ProjectedArrival.Seconds - trips[j-offset].lastStop().train.ProjectedArrival.Seconds
+			if travelTime <= 0 || travelTime > int64(maxTravelTime.Seconds()) {
+				valid = false
+				break
+			}
+			numMatches++
+			totalTravelTime += travelTime
+		}
+		if !valid || numMatches == 0 {
+			continue
+		}
+		if numMatches > bestNumMatches || (numMatches == bestNumMatches && totalTravelTime < bestTotalTravelTime) {
+			bestOffset = offset
+			bestNumMatches = numMatches
+			bestTotalTravelTime = totalTravelTime
+		}
+	}
+	return bestOffset
+}
+
+func sortTrainsByArrival(trains []Train) {
+	sort.SliceStable(trains, func(i, j int) bool {
+		return trains[i].ProjectedArrival.Seconds < trains[j].ProjectedArrival.Seconds
+	})
+}
@@ -0,0 +1,233 @@
+package pathgtfsrt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestStitchTrips(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      map[sourceapi.Station][]Train
+		wantTrips [][]string
+	}{
+		{
+			name: "single train along the route",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 13, 5),
+				},
+				sourceapi.Station_JOURNAL_SQUARE: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 18, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10", "HARRISON@13", "JOURNAL_SQUARE@18"},
+			},
+		},
+		{
+			name: "single train along the route, to NJ",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NJ, 18, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NJ, 13, 5),
+				},
+				sourceapi.Station_JOURNAL_SQUARE: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NJ, 10, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"JOURNAL_SQUARE@10", "HARRISON@13", "NEWARK@18"},
+			},
+		},
+		{
+			name: "train that has already passed the first station",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 20, 5),
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 8, 5),
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 13, 5),
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 23, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"HARRISON@8"},
+				{"NEWARK@10", "HARRISON@13"},
+				{"NEWARK@20", "HARRISON@23"},
+			},
+		},
+		{
+			name: "station with no data is skipped",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+				},
+				sourceapi.Station_JOURNAL_SQUARE: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 22, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10", "JOURNAL_SQUARE@22"},
+			},
+		},
+		{
+			name: "implausible travel time",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 25, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10"},
+				{"HARRISON@25"},
+			},
+		},
+		{
+			name: "different directions are not stitched",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NJ, 13, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10"},
+				{"HARRISON@13"},
+			},
+		},
+		{
+			name: "different headsigns are not stitched",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "World Trade Center", 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "Journal Square", 13, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10"},
+				{"HARRISON@13"},
+			},
+		},
+		{
+			name: "different spellings of a headsign are stitched",
+			data: map[sourceapi.Station][]Train{
+				sourceapi.Station_NEWARK: {
+					sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "World Trade Center", 10, 5),
+				},
+				sourceapi.Station_HARRISON: {
+					sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "WTC", 13, 5),
+				},
+			},
+			wantTrips: [][]string{
+				{"NEWARK@10", "HARRISON@13"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			staticData := staticData{
+				routeToRouteId: map[sourceapi.Route]string{
+					sourceapi.Route_NWK_WTC: routeID1,
+				},
+			}
+			for station := range tc.data {
+				staticData.stations = append(staticData.stations, station)
+			}
+
+			trips := stitchTrips(staticData, tc.data)
+
+			var gotTrips [][]string
+			for _, trip := range trips {
+				var gotTrip []string
+				for _, stop := range trip.stops {
+					gotTrip = append(gotTrip, fmt.Sprintf("%s@%d", stop.station,
+						stop.train.ProjectedArrival.AsTime().Minute()))
+				}
+				gotTrips = append(gotTrips, gotTrip)
+			}
+			if diff := cmp.Diff(tc.wantTrips, gotTrips); diff != "" {
+				t.Errorf("stitchTrips() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func sourceTrainWithHeadsign(route sourceapi.Route, direction sourceapi.Direction, headsign string, projectedArrival int, lastUpdated int) Train {
+	train := sourceTrain(route, direction, projectedArrival, lastUpdated)
+	train.Headsign = headsign
+	return train
+}
+
+func TestNormalizeHeadsign(t *testing.T) {
+	for _, tc := range []struct {
+		headsign string
+		want     string
+	}{
+		{headsign: "33rd Street", want: "33rd street"},
+		{headsign: "33rd St.", want: "33rd street"},
+		{headsign: "WTC", want: "world trade center"},
+		{headsign: "World Trade Centre", want: "world trade center"},
+		{headsign: "Journal Sq via Hoboken", want: "journal square via hoboken"},
+		{headsign: "", want: ""},
+	} {
+		if got := normalizeHeadsign(tc.headsign); got != tc.want {
+			t.Errorf("normalizeHeadsign(%q) got=%q, want=%q", tc.headsign, got, tc.want)
+		}
+	}
+}
+
+// Merged trains take the headsign of the freshest source, so the headsign of a train can be spelled differently
+// at different stations.
+func TestMergeTrainsThenStitchTrips(t *testing.T) {
+	pathData := map[sourceapi.Station][]Train{
+		sourceapi.Station_NEWARK: {
+			sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "World Trade Center", 10, 5),
+		},
+		sourceapi.Station_HARRISON: {
+			sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "World Trade Center", 13, 4),
+		},
+	}
+	panynj := map[sourceapi.Station][]Train{
+		sourceapi.Station_NEWARK: {
+			sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "WTC", 10, 4),
+		},
+		sourceapi.Station_HARRISON: {
+			sourceTrainWithHeadsign(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, "WTC", 13, 5),
+		},
+	}
+	realtimeData := map[sourceapi.Station][]Train{}
+	for _, station := range []sourceapi.Station{sourceapi.Station_NEWARK, sourceapi.Station_HARRISON} {
+		realtimeData[station] = mergeTrains([][]Train{pathData[station], panynj[station]}, func(time.Duration) {})
+	}
+	staticData := staticData{
+		stations:       []sourceapi.Station{sourceapi.Station_NEWARK, sourceapi.Station_HARRISON},
+		routeToRouteId: map[sourceapi.Route]string{sourceapi.Route_NWK_WTC: routeID1},
+	}
+
+	trips := stitchTrips(staticData, realtimeData)
+
+	if len(trips) != 1 {
+		t.Fatalf("number of trips got=%d, want=1", len(trips))
+	}
+	if got := len(trips[0].stops); got != 2 {
+		t.Errorf("number of stops got=%d, want=2", got)
+	}
+}
@@ -0,0 +1,104 @@
+package pathgtfsrt
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+// The maximum amount a train's predicted arrival at a station can change between two updates
+// for the train to still be identified as the same train.
+const maxPredictionChange = 5 * time.Minute
+
+// tripTracker assigns persistent IDs to trips across feed updates.
+//
+// The source APIs don't provide any identifier for trains, so the tracker matches the trips in each
+// update with the trips in the previous update. Two trips match if they have the same route and
+// direction, stop at a common station, and their predicted arrivals at that station are close.
+// A matched trip inherits the ID of the previous trip, so the ID is stable for the lifetime of the train.
+type tripTracker struct {
+	previousTrips []*trip
+}
+
+func newTripTracker() *tripTracker {
+	return &tripTracker{}
+}
+
+// Sets the ID of each of the trips, reusing the IDs of matching trips from the previous call.
+func (tracker *tripTracker) assignIds(trips []*trip) {
+	type candidate struct {
+		current  *trip
+		previous *trip
+		diff     int64
+	}
+	var candidates []candidate
+	for _, current := range trips {
+		for _, previous := range tracker.previousTrips {
+			if current.route != previous.route || current.direction != previous.direction {
+				continue
+			}
+			diff, ok := closestArrivalDiff(current, previous)
+			if !ok || diff > int64(maxPredictionChange.Seconds()) {
+				continue
+			}
+			candidates = append(candidates, candidate{current: current, previous: previous, diff: diff})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].diff < candidates[j].diff
+	})
+	matchedPrevious := map[*trip]bool{}
+	usedIds := map[string]bool{}
+	for _, c := range candidates {
+		if c.current.id != "" || matchedPrevious[c.previous] {
+			continue
+		}
+		c.current.id = c.previous.id
+		matchedPrevious[c.previous] = true
+		usedIds[c.current.id] = true
+	}
+	for _, current := range trips {
+		if current.id != "" {
+			continue
+		}
+		id := newTripId(current)
+		for i := 2; usedIds[id]; i++ {
+			id = fmt.Sprintf("%s_%d", newTripId(current), i)
+		}
+		current.id = id
+		usedIds[id] = true
+	}
+	tracker.previousTrips = trips
+}
+
+// Returns the smallest difference in predicted arrival times between the two trips at a common station.
+func closestArrivalDiff(a, b *trip) (int64, bool) {
+	stationToArrival := map[sourceapi.Station]int64{}
+	for _, stop := range b.stops {
+		stationToArrival[stop.station] = stop.train.ProjectedArrival.Seconds
+	}
+	var result int64
+	found := false
+	for _, stop := range a.stops {
+		arrival, ok := stationToArrival[stop.station]
+		if !ok {
+			continue
+		}
+		diff := stop.train.ProjectedArrival.Seconds - arrival
+		if diff < 0 {
+			diff = -diff
+		}
+		if !found || diff < result {
+			result = diff
+			found = true
+		}
+	}
+	return result, found
+}
+
+// Builds the ID for a trip that has just been seen for the first time.
+func newTripId(t *trip) string {
+	return fmt.Sprintf("%s_%s_%d", t.route, t.direction, t.stops[0].train.ProjectedArrival.Seconds)
+}
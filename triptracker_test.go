@@ -0,0 +1,165 @@
+package pathgtfsrt
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+)
+
+func TestTripTracker(t *testing.T) {
+	staticData := staticData{
+		stations: []sourceapi.Station{
+			sourceapi.Station_NEWARK,
+			sourceapi.Station_HARRISON,
+		},
+		routeToRouteId: map[sourceapi.Route]string{
+			sourceapi.Route_NWK_WTC: routeID1,
+		},
+	}
+	firstTrainId := "NWK_WTC_TO_NY_" + unixString(10)
+	secondTrainId := "NWK_WTC_TO_NY_" + unixString(20)
+	for _, tc := range []struct {
+		name    string
+		updates []map[sourceapi.Station][]Train
+		wantIds [][]string
+	}{
+		{
+			name: "prediction changes",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 20, 5),
+					},
+				},
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 11, 6),
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 19, 6),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId, secondTrainId},
+				{firstTrainId, secondTrainId},
+			},
+		},
+		{
+			name: "train leaves the first station",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+					},
+					sourceapi.Station_HARRISON: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 13, 5),
+					},
+				},
+				{
+					sourceapi.Station_HARRISON: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 14, 11),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId},
+				{firstTrainId},
+			},
+		},
+		{
+			name: "new train",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+					},
+				},
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 6),
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 20, 6),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId},
+				{firstTrainId, secondTrainId},
+			},
+		},
+		{
+			name: "prediction changes too much",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+					},
+				},
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 20, 6),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId},
+				{secondTrainId},
+			},
+		},
+		{
+			name: "different direction",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+					},
+				},
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NJ, 10, 6),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId},
+				{"NWK_WTC_TO_NJ_" + unixString(10)},
+			},
+		},
+		{
+			name: "duplicate new IDs",
+			updates: []map[sourceapi.Station][]Train{
+				{
+					sourceapi.Station_NEWARK: {
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+						sourceTrain(sourceapi.Route_NWK_WTC, sourceapi.Direction_TO_NY, 10, 5),
+					},
+				},
+			},
+			wantIds: [][]string{
+				{firstTrainId, firstTrainId + "_2"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := newTripTracker()
+			var gotIds [][]string
+			for _, update := range tc.updates {
+				trips := stitchTrips(staticData, update)
+				tracker.assignIds(trips)
+				var ids []string
+				for _, trip := range trips {
+					ids = append(ids, trip.id)
+				}
+				gotIds = append(gotIds, ids)
+			}
+			if diff := cmp.Diff(tc.wantIds, gotIds); diff != "" {
+				t.Errorf("assignIds() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func unixString(t int) string {
+	return fmt.Sprintf("%d", *makeUnix(t))
+}
@@ -0,0 +1,64 @@
+package pathgtfsrt
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+	gtfs "github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+)
+
+const (
+	// A train whose predicted arrival at a station is within this window of the current time,
+	// either before or after, is considered to be stopped at the station.
+	stoppedAtWindow = 30 * time.Second
+	// A train whose predicted arrival at a station is within this window after the current time
+	// is considered to be about to arrive at the station.
+	incomingAtWindow = 90 * time.Second
+)
+
+// Build a GTFS Realtime message containing the inferred positions of the trains in the trips.
+//
+// PATH doesn't publish train positions, so the position of each train is inferred from its predicted
+// arrival times: the train is at, or travelling to, the first station in the trip that it hasn't yet left.
+// Trips that have left all of their stations are omitted.
+func buildVehiclePositionsFeedMessage(clock clock.Clock, staticData staticData, trips []*trip) *gtfs.FeedMessage {
+	now := clock.Now()
+	var entities []*gtfs.FeedEntity
+	for _, trip := range trips {
+		for _, stop := range trip.stops {
+			untilArrival := stop.train.ProjectedArrival.AsTime().Sub(now)
+			if untilArrival < -stoppedAtWindow {
+				continue
+			}
+			var status gtfs.VehiclePosition_VehicleStopStatus
+			switch {
+			case untilArrival <= stoppedAtWindow:
+				status = gtfs.VehiclePosition_STOPPED_AT
+			case untilArrival <= incomingAtWindow:
+				status = gtfs.VehiclePosition_INCOMING_AT
+			default:
+				status = gtfs.VehiclePosition_IN_TRANSIT_TO
+			}
+			entities = append(entities, &gtfs.FeedEntity{
+				Id: ptr(vehicleEntityId(trip)),
+				Vehicle: &gtfs.VehiclePosition{
+					Trip: buildTripDescriptor(staticData, trip),
+					Vehicle: &gtfs.VehicleDescriptor{
+						Id: ptr(trip.id),
+					},
+					StopId:        ptr(staticData.stopId(stop.station, trip.route, trip.direction)),
+					CurrentStatus: status.Enum(),
+					Timestamp:     ptr(uint64(trip.lastUpdated())),
+				},
+			})
+			break
+		}
+	}
+	return newFeedMessage(clock, entities)
+}
+
+// Returns the ID of the vehicle entity for a trip. This is distinct from the ID of the trip
+// update entity so that both entities can appear in the same feed.
+func vehicleEntityId(trip *trip) string {
+	return "vehicle_" + trip.id
+}
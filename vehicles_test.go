@@ -0,0 +1,104 @@
+package pathgtfsrt
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jamespfennell/path-train-gtfs-realtime/proto/gtfsrt"
+	sourceapi "github.com/jamespfennell/path-train-gtfs-realtime/proto/sourceapi"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestBuildVehiclePositionsFeedMessage(t *testing.T) {
+	const stopIDNewark = "stopIDNewark"
+	const stopIDHarrison = "stopIDHarrison"
+	for _, tc := range []struct {
+		name       string
+		stops      map[sourceapi.Station]int
+		wantStopID string
+		wantStatus gtfsrt.VehiclePosition_VehicleStopStatus
+		wantOmit   bool
+	}{
+		{
+			name:       "stopped at first station",
+			stops:      map[sourceapi.Station]int{sourceapi.Station_NEWARK: 10, sourceapi.Station_HARRISON: 13},
+			wantStopID: stopIDNewark,
+			wantStatus: gtfsrt.VehiclePosition_STOPPED_AT,
+		},
+		{
+			name:       "incoming at station",
+			stops:      map[sourceapi.Station]int{sourceapi.Station_HARRISON: 11},
+			wantStopID: stopIDHarrison,
+			wantStatus: gtfsrt.VehiclePosition_INCOMING_AT,
+		},
+		{
+			name:       "in transit to station",
+			stops:      map[sourceapi.Station]int{sourceapi.Station_NEWARK: 5, sourceapi.Station_HARRISON: 14},
+			wantStopID: stopIDHarrison,
+			wantStatus: gtfsrt.VehiclePosition_IN_TRANSIT_TO,
+		},
+		{
+			name:     "left all stations",
+			stops:    map[sourceapi.Station]int{sourceapi.Station_NEWARK: 5, sourceapi.Station_HARRISON: 8},
+			wantOmit: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := clock.NewMock()
+			c.Set(makeTime(10))
+			staticData := staticData{
+				stationToStopId: map[sourceapi.Station]string{
+					sourceapi.Station_NEWARK:   stopIDNewark,
+					sourceapi.Station_HARRISON: stopIDHarrison,
+				},
+				routeToRouteId: map[sourceapi.Route]string{
+					sourceapi.Route_NWK_WTC: routeID1,
+				},
+			}
+			tr := &trip{
+				id:        "tripID",
+				route:     sourceapi.Route_NWK_WTC,
+				direction: sourceapi.Direction_TO_NY,
+			}
+			for _, station := range stationsInDirection(tr.route, tr.direction) {
+				arrival, ok := tc.stops[station]
+				if !ok {
+					continue
+				}
+				tr.stops = append(tr.stops, tripStop{
+					station: station,
+					train:   sourceTrain(tr.route, tr.direction, arrival, 9),
+				})
+			}
+
+			msg := buildVehiclePositionsFeedMessage(c, staticData, []*trip{tr})
+
+			var wantEntities []*gtfsrt.FeedEntity
+			if !tc.wantOmit {
+				wantEntities = append(wantEntities, &gtfsrt.FeedEntity{
+					Id: ptr("vehicle_tripID"),
+					Vehicle: &gtfsrt.VehiclePosition{
+						Trip: &gtfsrt.TripDescriptor{
+							TripId:      ptr("tripID"),
+							RouteId:     ptr(routeID1),
+							DirectionId: ptr(uint32(1)),
+						},
+						Vehicle: &gtfsrt.VehicleDescriptor{
+							Id: ptr("tripID"),
+						},
+						StopId:        ptr(tc.wantStopID),
+						CurrentStatus: tc.wantStatus.Enum(),
+						Timestamp:     ptr(uint64(makeTime(9).Unix())),
+					},
+				})
+			}
+			if diff := cmp.Diff(wantEntities, msg.Entity, protocmp.Transform()); diff != "" {
+				t.Errorf("buildVehiclePositionsFeedMessage() mismatch (-want +got):\n%s", diff)
+			}
+			if got, want := msg.GetHeader().GetTimestamp(), uint64(c.Now().Unix()); got != want {
+				t.Errorf("header timestamp got=%d, want=%d", got, want)
+			}
+		})
+	}
+}